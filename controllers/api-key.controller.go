@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/utils"
+	"gorm.io/gorm"
+)
+
+type ApiKeyController struct {
+	DB *gorm.DB
+}
+
+func NewApiKeyController(DB *gorm.DB) ApiKeyController {
+	return ApiKeyController{DB}
+}
+
+// CreateApiKey mints a key for a user. The key is only ever shown in this
+// response.
+func (kc *ApiKeyController) CreateApiKey(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.CreateApiKeyInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	for _, scope := range payload.Scopes {
+		if !models.ValidApiKeyScope(scope) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Unknown scope " + scope})
+			return
+		}
+	}
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "An API key must expire in the future"})
+		return
+	}
+
+	user := currentUser
+	if payload.UserId != "" {
+		if kc.DB.First(&user, "id = ?", payload.UserId).Error != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No user with that ID exists"})
+			return
+		}
+	}
+
+	id, err := utils.GenerateRandomToken(6)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	prefix := models.ApiKeyPrefix + id
+	key := prefix + "." + secret
+
+	apiKey := models.ApiKey{
+		Name:      payload.Name,
+		Prefix:    prefix,
+		KeyHash:   utils.HashToken(key),
+		UserId:    user.ID,
+		Scopes:    payload.Scopes,
+		ExpiresAt: payload.ExpiresAt,
+		CreatedBy: currentUser.ID,
+		CreatedAt: time.Now(),
+	}
+	if err := kc.DB.Create(&apiKey).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": gin.H{"api_key": apiKey, "key": key}})
+}
+
+// FindApiKeys lists the API keys, newest first, optionally only those of
+// ?user_id= and, unless ?revoked=true, only the ones not revoked.
+func (kc *ApiKeyController) FindApiKeys(ctx *gin.Context) {
+	query := kc.DB.Model(&models.ApiKey{})
+	if userId := ctx.Query("user_id"); userId != "" {
+		query = query.Where("user_id = ?", userId)
+	}
+	if ctx.Query("revoked") != "true" {
+		query = query.Where("revoked_at IS NULL")
+	}
+
+	var apiKeys []models.ApiKey
+	results := query.Order("created_at DESC").Find(&apiKeys)
+	if results.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(apiKeys), "data": apiKeys})
+}
+
+// RevokeApiKey stops a key from working straight away.
+func (kc *ApiKeyController) RevokeApiKey(ctx *gin.Context) {
+	apiKeyId := ctx.Param("apiKeyId")
+
+	result := kc.DB.Model(&models.ApiKey{}).
+		Where("id = ? AND revoked_at IS NULL", apiKeyId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No active API key with that ID exists"})
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/models"
+	"gorm.io/gorm"
+)
+
+type AuditController struct {
+	DB *gorm.DB
+}
+
+func NewAuditController(DB *gorm.DB) AuditController {
+	return AuditController{DB}
+}
+
+// FindAuditLogs lists audit entries, newest first, optionally only those
+// about ?user_id= or with ?action=.
+func (auc *AuditController) FindAuditLogs(ctx *gin.Context) {
+	page := ctx.DefaultQuery("page", "1")
+	limit := ctx.DefaultQuery("limit", "10")
+
+	intPage, _ := strconv.Atoi(page)
+	intLimit, _ := strconv.Atoi(limit)
+	offset := (intPage - 1) * intLimit
+
+	query := auc.DB.Model(&models.AuditLog{})
+	if userId := ctx.Query("user_id"); userId != "" {
+		query = query.Where("user_id = ?", userId)
+	}
+	if action := ctx.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	var logs []models.AuditLog
+	results := query.Order("created_at DESC").Limit(intLimit).Offset(offset).Find(&logs)
+	if results.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "page": page, "results": len(logs), "total_results": total, "data": logs})
+}
+
+// recordAudit writes an audit entry about user, which may be nil when the
+// account is unknown, caused by actor when that is someone else. A failure
+// to write it is logged and does not fail the request.
+func recordAudit(db *gorm.DB, ctx *gin.Context, action string, username string, user *models.User, actor *models.User, detail string) {
+	entry := models.AuditLog{
+		Action:    action,
+		Username:  username,
+		ClientIP:  ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	}
+	if user != nil {
+		entry.UserId = &user.ID
+		entry.Username = user.Username
+	}
+	if actor != nil {
+		entry.ActorId = &actor.ID
+	}
+	if len(entry.UserAgent) > 255 {
+		entry.UserAgent = strings.ToValidUTF8(entry.UserAgent[:255], "")
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		log.Println("Failed to write audit log:", err)
+	}
+}
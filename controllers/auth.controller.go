@@ -1,26 +1,51 @@
 package controllers
 
 import (
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/mliem2k/ottb-go/initializers"
+	"github.com/mliem2k/ottb-go/keyring"
+	"github.com/mliem2k/ottb-go/lockout"
+	"github.com/mliem2k/ottb-go/mailer"
 	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/oidc"
+	"github.com/mliem2k/ottb-go/outbox"
 	"github.com/mliem2k/ottb-go/utils"
-	"gopkg.in/gomail.v2"
 	"gorm.io/gorm"
 )
 
+var (
+	errResetTokenUsed       = errors.New("reset token already used")
+	errVerificationCodeUsed = errors.New("verification code already used")
+	errOidcEmailTaken       = errors.New("email belongs to another account")
+	errOidcNoEmail          = errors.New("identity provider did not share an email address")
+	errMfaChallengeUsed     = errors.New("mfa challenge already used")
+	errUnlockTokenUsed      = errors.New("unlock token already used")
+)
+
 type AuthController struct {
 	DB *gorm.DB
+	// OIDC is nil when sign-in with an OpenID Connect provider is off.
+	OIDC *oidc.Provider
+	// Logins slows down and locks out password guessing.
+	Logins *lockout.Limiter
+	// AccessTokens signs access tokens with the current key.
+	AccessTokens *keyring.Ring
 }
 
-func NewAuthController(DB *gorm.DB) AuthController {
-	return AuthController{DB}
+func NewAuthController(DB *gorm.DB, OIDC *oidc.Provider, Logins *lockout.Limiter, AccessTokens *keyring.Ring) AuthController {
+	return AuthController{DB, OIDC, Logins, AccessTokens}
 }
 
 // SignUp User
@@ -55,74 +80,32 @@ func (ac *AuthController) SignUpUser(ctx *gin.Context) {
 		Username:  strings.ToLower(payload.Username),
 		Email:     strings.ToLower(payload.Email),
 		Password:  hashedPassword,
-		Role:      "user",
+		Role:      models.RoleUser,
 		Verified:  false,
 		Photo:     payload.Photo,
 		Provider:  "local",
+		Locale:    mailer.PreferredLocale(ctx.GetHeader("Accept-Language")),
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
-	result := ac.DB.Create(&newUser)
+	// The verification email is queued with the user, so an SMTP outage
+	// delays the email instead of failing the registration.
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		return ac.queueVerificationEmail(tx, &config, &newUser)
+	})
 
-	if result.Error != nil && strings.Contains(result.Error.Error(), "duplicate key value violates unique") {
+	if err != nil && strings.Contains(err.Error(), "duplicate key value violates unique") {
 		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": "User with that email or username already exists"})
 		return
-	} else if result.Error != nil {
+	} else if err != nil {
 		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
 		return
 	}
 
-	// Set up SMTP server configuration.
-	smtpServer := config.SmtpServer
-	smtpPort := config.SmtpPort
-	username := config.SmtpUser
-	password := config.SmtpPass
-
-	from := config.SmtpFrom
-	to := newUser.Email
-	subject := "Verify your OTTB account"
-	verificationLink := config.ServerOrigin + "/api/auth/verifyemail/" + newUser.ID.String()
-
-	// HTML body content
-	body := `
-	<html>
-	<head>
-		<title>Verify Your OTTB Account</title>
-	</head>
-	<body>
-		<p>Hello,</p>
-		<p>Please click the following link to verify your OTTB account:</p>
-		<p><a href="` + verificationLink + `">Verify Email</a></p>
-		<p>If you didn't request this, please ignore this email.</p>
-		<p>Thank you!</p>
-	</body>
-	</html>
-	`
-
-	// Set up the email message.
-	msg := gomail.NewMessage()
-	msg.SetHeader("From", from)
-	msg.SetHeader("To", to)
-	msg.SetHeader("Subject", subject)
-	msg.SetBody("text/html", body)
-
-	// Set up authentication information.
-	d := gomail.NewDialer(smtpServer, smtpPort, username, password)
-
-	// Send the email.
-	if err := d.DialAndSend(msg); err != nil {
-		log.Println("Failed to send email:", err)
-		// If there's an error, delete the newly created user
-		if deleteErr := ac.DB.Delete(&newUser).Error; deleteErr != nil {
-			log.Println("Failed to delete user:", deleteErr)
-		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "fail", "message": "Failed to send email."})
-		return
-	}
-
-	log.Println("Email sent successfully!")
-
 	userResponse := &models.UserResponse{
 		ID:        newUser.ID,
 		Name:      newUser.Name,
@@ -133,33 +116,57 @@ func (ac *AuthController) SignUpUser(ctx *gin.Context) {
 		Provider:  newUser.Provider,
 		CreatedAt: newUser.CreatedAt,
 		UpdatedAt: newUser.UpdatedAt,
+
+		NotifyOnDeveloped: newUser.NotifyOnDeveloped,
+		MfaEnabled:        newUser.MfaEnabled(),
 	}
 	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": gin.H{"user": userResponse}})
 }
 
 func (ac *AuthController) VerifyEmail(ctx *gin.Context) {
-	userId := ctx.Param("userId")
-	var user models.User
-	result := ac.DB.First(&user, "id = ?", userId)
+	code := ctx.Param("code")
+	message := "The verification link is invalid or has expired"
+	now := time.Now()
+
+	var verification models.EmailVerification
+	result := ac.DB.First(&verification, "code_hash = ? AND consumed_at IS NULL AND expires_at > ?", utils.HashToken(code), now)
 	if result.Error != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid user ID"})
-		return
-	}
-	user.Verified = true
-	ac.DB.Save(&user)
-	htmlResponse := `
-	<html>
-	<head>
-		<title>OTTB Email Verification</title>
-	</head>
-	<body style="font-family: Arial, sans-serif; background-color: #f0f0f0; padding: 20px;">
-		<div style="max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 5px; padding: 20px; box-shadow: 0px 2px 5px 0px rgba(0,0,0,0.1);">
-			<h1 style="color: #333333; text-align: center;">OTTB Email Verified Successfully</h1>
-			<p style="color: #666666; text-align: center;">Your email has been successfully verified.</p>
-		</div>
-	</body>
-	</html>
-`
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	}
+
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		claimed := tx.Model(&models.EmailVerification{}).
+			Where("id = ? AND consumed_at IS NULL", verification.ID).
+			Update("consumed_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected != 1 {
+			return errVerificationCodeUsed
+		}
+
+		return tx.Model(&models.User{}).
+			Where("id = ?", verification.UserId).
+			Updates(map[string]interface{}{"verified": true, "updated_at": now}).Error
+	})
+	if err == errVerificationCodeUsed {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	htmlResponse, err := mailer.RenderPage("verifysuccessful.tmpl", map[string]interface{}{
+		"Title":   "OTTB Email Verified Successfully",
+		"Message": "Your email has been successfully verified.",
+	})
+	if err != nil {
+		log.Println("Failed to render verification page:", err)
+		ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Your email has been successfully verified"})
+		return
+	}
 
 	ctx.Header("Content-Type", "text/html")
 	ctx.String(http.StatusOK, htmlResponse)
@@ -173,38 +180,202 @@ func (ac *AuthController) SignInUser(ctx *gin.Context) {
 		return
 	}
 
+	config, _ := initializers.LoadConfig(".")
+	username := strings.ToLower(payload.Username)
+	failures, ok := ac.attempt(ctx, username)
+	if !ok {
+		return
+	}
+
 	var user models.User
-	result := ac.DB.First(&user, "username = ?", strings.ToLower(payload.Username))
+	result := ac.DB.First(&user, "username = ?", username)
 	if result.Error != nil {
+		ac.loginFailed(ctx, &config, models.AuditLoginFailed, username, nil, failures)
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid username or Password"})
 		return
 	}
 
 	if err := utils.VerifyPassword(user.Password, payload.Password); err != nil {
+		ac.loginFailed(ctx, &config, models.AuditLoginFailed, username, &user, failures)
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid username or Password"})
 		return
 	}
+	// Only someone who knows the password learns that the account is locked
+	if user.Locked(time.Now()) {
+		ac.cancelAttempt(ctx, username)
+		respondLocked(ctx, &user)
+		return
+	}
+	if err := ac.Logins.Succeed(username, ctx.ClientIP()); err != nil {
+		log.Println("Failed to reset failed sign-ins:", err)
+	}
 
-	config, _ := initializers.LoadConfig(".")
+	if user.VerificationRequired(config.EmailVerificationGracePeriod) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "Please verify your email address before logging in"})
+		return
+	}
+
+	// With two-factor authentication the tokens wait for VerifyLoginMfa
+	purpose, mfaToken, err := startMfaChallenge(ac.DB, &config, &user)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+	if purpose != "" {
+		ctx.JSON(http.StatusOK, gin.H{
+			"status":                  "success",
+			"mfa_required":            true,
+			"mfa_enrollment_required": purpose == models.MfaPurposeEnroll,
+			"mfa_token":               mfaToken,
+		})
+		return
+	}
 
 	// Generate Tokens
-	access_token, err := utils.CreateToken(config.AccessTokenExpiresIn, user.ID, config.AccessTokenPrivateKey)
+	access_token, err := ac.issueTokens(ctx, &config, user.ID, uuid.New())
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
 
-	refresh_token, err := utils.CreateToken(config.RefreshTokenExpiresIn, user.ID, config.RefreshTokenPrivateKey)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
+}
+
+// StartLoginMfaEnrollment gives a user who must set up two-factor
+// authentication before signing in a TOTP secret, for the mfa_token they got
+// from SignInUser.
+func (ac *AuthController) StartLoginMfaEnrollment(ctx *gin.Context) {
+	var payload *models.MfaTokenInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	var challenge models.MfaChallenge
+	result := ac.DB.First(&challenge, "token_hash = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?",
+		utils.HashToken(payload.MfaToken), models.MfaPurposeEnroll, time.Now())
+	if result.Error != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "The sign-in has expired, please log in again"})
+		return
+	}
+
+	var user models.User
+	if ac.DB.First(&user, "id = ?", challenge.UserId).Error != nil || user.MfaEnabled() {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "The sign-in has expired, please log in again"})
+		return
+	}
+
+	config, _ := initializers.LoadConfig(".")
+	enrollment, err := startTotpEnrollment(ac.DB, &config, &user)
 	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": enrollment})
+}
+
+// VerifyLoginMfa finishes a sign-in with a TOTP code or a recovery code. For
+// a user still setting up two-factor authentication the code confirms the
+// new secret, and the recovery codes are returned as well.
+func (ac *AuthController) VerifyLoginMfa(ctx *gin.Context) {
+	var payload *models.MfaLoginInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
+	expired := "The sign-in has expired, please log in again"
+	now := time.Now()
 
-	ctx.SetCookie("access_token", access_token, config.AccessTokenMaxAge*60, "/", config.ServerOrigin, false, true)
-	ctx.SetCookie("refresh_token", refresh_token, config.RefreshTokenMaxAge*60, "/", config.ServerOrigin, false, true)
-	ctx.SetCookie("logged_in", "true", config.AccessTokenMaxAge*60, "/", config.ServerOrigin, false, false)
+	// Every try counts, so codes cannot be guessed against one challenge
+	tokenHash := utils.HashToken(payload.MfaToken)
+	claimed := ac.DB.Model(&models.MfaChallenge{}).
+		Where("token_hash = ? AND consumed_at IS NULL AND expires_at > ? AND attempts < ?", tokenHash, now, maxMfaAttempts).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+	if claimed.Error != nil || claimed.RowsAffected != 1 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": expired})
+		return
+	}
 
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
+	var challenge models.MfaChallenge
+	var user models.User
+	if ac.DB.First(&challenge, "token_hash = ?", tokenHash).Error != nil || ac.DB.First(&user, "id = ?", challenge.UserId).Error != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": expired})
+		return
+	}
+	if user.Locked(now) {
+		respondLocked(ctx, &user)
+		return
+	}
+	failures, allowed := ac.attempt(ctx, user.Username)
+	if !allowed {
+		return
+	}
+	config, _ := initializers.LoadConfig(".")
+
+	var ok bool
+	switch {
+	case challenge.Purpose == models.MfaPurposeEnroll:
+		if user.MfaSecret == "" {
+			ac.cancelAttempt(ctx, user.Username)
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Start setting up two-factor authentication first"})
+			return
+		}
+		ok = acceptTotp(ac.DB, &user, payload.Code)
+	case payload.Code != "":
+		ok = acceptTotp(ac.DB, &user, payload.Code)
+	case payload.RecoveryCode != "":
+		ok = useRecoveryCode(ac.DB, &user, payload.RecoveryCode)
+	}
+	if !ok {
+		// Wrong codes count like wrong passwords, so that new challenges do
+		// not give endless guesses
+		ac.loginFailed(ctx, &config, models.AuditMfaFailed, user.Username, &user, failures)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "Invalid code"})
+		return
+	}
+	if err := ac.Logins.Succeed(user.Username, ctx.ClientIP()); err != nil {
+		log.Println("Failed to reset failed sign-ins:", err)
+	}
+
+	var recoveryCodes []string
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		consumed := tx.Model(&models.MfaChallenge{}).
+			Where("id = ? AND consumed_at IS NULL", challenge.ID).
+			UpdateColumn("consumed_at", now)
+		if consumed.Error != nil {
+			return consumed.Error
+		}
+		if consumed.RowsAffected != 1 {
+			return errMfaChallengeUsed
+		}
+
+		if challenge.Purpose != models.MfaPurposeEnroll {
+			return nil
+		}
+		var err error
+		recoveryCodes, err = enableMfa(tx, &user)
+		return err
+	})
+	if err == errMfaChallengeUsed {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": expired})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	access_token, err := ac.issueTokens(ctx, &config, user.ID, uuid.New())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	response := gin.H{"status": "success", "access_token": access_token}
+	if recoveryCodes != nil {
+		response["recovery_codes"] = recoveryCodes
+	}
+	ctx.JSON(http.StatusOK, response)
 }
 
 // Refresh Access Token
@@ -220,28 +391,59 @@ func (ac *AuthController) RefreshAccessToken(ctx *gin.Context) {
 
 	config, _ := initializers.LoadConfig(".")
 
-	sub, err := utils.ValidateToken(cookie, config.RefreshTokenPublicKey)
+	claims, err := utils.ValidateTokenClaims(cookie, config.RefreshTokenPublicKey)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
 
+	var session models.Session
+	result := ac.DB.First(&session, "id = ?", fmt.Sprint(claims["jti"]))
+	if result.Error != nil || session.UserId.String() != fmt.Sprint(claims["sub"]) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": message})
+		return
+	}
+
+	if session.RevokedAt != nil {
+		// A refresh token that was already rotated or revoked is being replayed,
+		// so whoever holds the newer tokens of this family can no longer be trusted.
+		if session.ReplacedBy != nil {
+			log.Println("Refresh token reuse detected for user", session.UserId, "- revoking session family", session.FamilyId)
+			ac.revokeSessionFamily(session.FamilyId)
+		}
+		ac.clearAuthCookies(ctx, &config)
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": message})
+		return
+	}
+
 	var user models.User
-	result := ac.DB.First(&user, "id = ?", fmt.Sprint(sub))
+	result = ac.DB.First(&user, "id = ?", session.UserId)
 	if result.Error != nil {
 		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "the user belonging to this token no logger exists"})
 		return
 	}
 
-	access_token, err := utils.CreateToken(config.AccessTokenExpiresIn, user.ID, config.AccessTokenPrivateKey)
+	// Claim the old session before issuing a new one so two concurrent
+	// refreshes with the same token cannot both succeed.
+	replacement := uuid.New()
+	now := time.Now()
+	result = ac.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", session.ID).
+		Updates(map[string]interface{}{"revoked_at": now, "replaced_by": replacement, "updated_at": now})
+	if result.Error != nil || result.RowsAffected != 1 {
+		log.Println("Refresh token reuse detected for user", session.UserId, "- revoking session family", session.FamilyId)
+		ac.revokeSessionFamily(session.FamilyId)
+		ac.clearAuthCookies(ctx, &config)
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": message})
+		return
+	}
+
+	access_token, err := ac.issueTokensWithID(ctx, &config, user.ID, session.FamilyId, replacement)
 	if err != nil {
 		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
 
-	ctx.SetCookie("access_token", access_token, config.AccessTokenMaxAge*60, "/", config.ServerOrigin, false, true)
-	ctx.SetCookie("logged_in", "true", config.AccessTokenMaxAge*60, "/", config.ServerOrigin, false, false)
-
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "access_token": access_token})
 }
 
@@ -250,9 +452,705 @@ func (ac *AuthController) LogoutUser(ctx *gin.Context) {
 	if err != nil {
 		log.Fatal("🚀 Could not load environment variables", err)
 	}
+
+	if cookie, err := ctx.Cookie("refresh_token"); err == nil {
+		if claims, err := utils.ValidateTokenClaims(cookie, config.RefreshTokenPublicKey); err == nil {
+			var session models.Session
+			if ac.DB.First(&session, "id = ?", fmt.Sprint(claims["jti"])).Error == nil {
+				ac.revokeSessionFamily(session.FamilyId)
+			}
+		}
+	}
+
+	ac.clearAuthCookies(ctx, &config)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// ResendVerification mails a fresh verification link. Like ForgotPassword it
+// never reveals whether the address belongs to an account, and requests within
+// the cooldown are silently dropped.
+func (ac *AuthController) ResendVerification(ctx *gin.Context) {
+	var payload *models.ResendVerificationInput
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	message := "If an unverified account with that email exists, a verification link has been sent"
+
+	var user models.User
+	result := ac.DB.First(&user, "email = ?", strings.ToLower(payload.Email))
+	if result.Error != nil || user.Verified {
+		ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": message})
+		return
+	}
+
+	config, _ := initializers.LoadConfig(".")
+
+	var recent int64
+	ac.DB.Model(&models.EmailVerification{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-config.EmailVerificationResendCooldown)).
+		Count(&recent)
+	if recent > 0 {
+		ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": message})
+		return
+	}
+
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		return ac.queueVerificationEmail(tx, &config, &user)
+	})
+	if err != nil {
+		log.Println("Failed to queue verification email:", err)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": message})
+}
+
+// ForgotPassword mails a reset code to the account owner. The response is the
+// same whether or not the email belongs to an account.
+func (ac *AuthController) ForgotPassword(ctx *gin.Context) {
+	var payload *models.ForgotPasswordInput
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	message := "If an account with that email exists, a password reset link has been sent"
+
+	var user models.User
+	result := ac.DB.First(&user, "email = ?", strings.ToLower(payload.Email))
+	if result.Error != nil {
+		ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": message})
+		return
+	}
+
+	config, _ := initializers.LoadConfig(".")
+
+	resetToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	email, err := mailer.Render("resetpassword", user.Locale, map[string]interface{}{
+		"Name":      user.Name,
+		"Link":      config.ClientOrigin + "/resetpassword/" + resetToken,
+		"ExpiresIn": config.PasswordResetExpiresIn.String(),
+	})
+	if err != nil {
+		log.Println("Failed to render email:", err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	now := time.Now()
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the most recently mailed code stays usable.
+		if err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PasswordReset{
+			UserId:    user.ID,
+			TokenHash: utils.HashToken(resetToken),
+			ExpiresAt: now.Add(config.PasswordResetExpiresIn),
+			CreatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, user.Email, email)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": message})
+}
+
+// ResetPassword consumes a reset code, sets the new password and revokes every
+// session the user has open.
+func (ac *AuthController) ResetPassword(ctx *gin.Context) {
+	resetToken := ctx.Param("token")
+
+	var payload *models.ResetPasswordInput
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	if payload.Password != payload.PasswordConfirm {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Passwords do not match"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(payload.Password)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	message := "The reset token is invalid or has expired"
+	now := time.Now()
+
+	var reset models.PasswordReset
+	result := ac.DB.First(&reset, "token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(resetToken), now)
+	if result.Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	}
+
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		claimed := tx.Model(&models.PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected != 1 {
+			return errResetTokenUsed
+		}
+
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", reset.UserId).
+			Updates(map[string]interface{}{"password": hashedPassword, "updated_at": now}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", reset.UserId).
+			Updates(map[string]interface{}{"revoked_at": now, "updated_at": now}).Error
+	})
+	if err == errResetTokenUsed {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	config, _ := initializers.LoadConfig(".")
+	ac.clearAuthCookies(ctx, &config)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Password updated successfully, please log in again"})
+}
+
+// UnlockAccount consumes a mailed unlock code and lifts the lock on the
+// account straight away.
+func (ac *AuthController) UnlockAccount(ctx *gin.Context) {
+	token := ctx.Param("token")
+	message := "The unlock link is invalid or has expired"
+	now := time.Now()
+
+	var unlock models.AccountUnlock
+	result := ac.DB.First(&unlock, "token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(token), now)
+	if result.Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	}
+
+	var user models.User
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		claimed := tx.Model(&models.AccountUnlock{}).
+			Where("id = ? AND used_at IS NULL", unlock.ID).
+			Update("used_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected != 1 {
+			return errUnlockTokenUsed
+		}
+
+		if err := tx.First(&user, "id = ?", unlock.UserId).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).UpdateColumn("locked_until", nil).Error
+	})
+	if err == errUnlockTokenUsed {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+	recordAudit(ac.DB, ctx, models.AuditAccountUnlocked, "", &user, nil, "unlocked from the email link")
+
+	htmlResponse, err := mailer.RenderPage("verifysuccessful.tmpl", map[string]interface{}{
+		"Title":   "OTTB Account Unlocked",
+		"Message": "Your account has been unlocked, you can log in again.",
+	})
+	if err != nil {
+		log.Println("Failed to render unlock page:", err)
+		ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Your account has been unlocked"})
+		return
+	}
+
+	ctx.Header("Content-Type", "text/html")
+	ctx.String(http.StatusOK, htmlResponse)
+}
+
+// OidcLogin sends the browser to the OIDC provider to sign in, which returns
+// it to OidcCallback. ?redirect= is the client path to land on afterwards.
+func (ac *AuthController) OidcLogin(ctx *gin.Context) {
+	if ac.OIDC == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "Signing in with an identity provider is not enabled"})
+		return
+	}
+	config, _ := initializers.LoadConfig(".")
+
+	redirectTo := ctx.Query("redirect")
+	if !isClientPath(redirectTo) {
+		redirectTo = "/"
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	verifier, challenge, err := oidc.NewVerifier()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	authURL, err := ac.OIDC.AuthCodeURL(ctx.Request.Context(), state, nonce, challenge)
+	if err != nil {
+		log.Println("OIDC:", err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "The identity provider is unavailable"})
+		return
+	}
+
+	now := time.Now()
+	// Forget sign-ins that were abandoned at the provider
+	ac.DB.Where("expires_at < ?", now).Delete(&models.OidcLogin{})
+
+	login := models.OidcLogin{
+		StateHash:    utils.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectTo:   redirectTo,
+		ExpiresAt:    now.Add(config.OidcLoginTimeout),
+		CreatedAt:    now,
+	}
+	if err := ac.DB.Create(&login).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	// The state must come back in the same browser, so that nobody can slip
+	// their own sign-in into someone else's
+	ctx.SetCookie("oidc_state", state, int(config.OidcLoginTimeout.Seconds()), "/api/auth/oidc", config.ServerOrigin, false, true)
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// OidcCallback finishes a sign-in started by OidcLogin: it checks the state,
+// trades the code for an ID token, finds or creates the user and issues our
+// usual tokens before returning to the client.
+func (ac *AuthController) OidcCallback(ctx *gin.Context) {
+	if ac.OIDC == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "Signing in with an identity provider is not enabled"})
+		return
+	}
+	config, _ := initializers.LoadConfig(".")
+	message := "The sign-in could not be completed, please try again"
+
+	if reason := ctx.Query("error"); reason != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "The identity provider refused the sign-in: " + reason})
+		return
+	}
+
+	state := ctx.Query("state")
+	code := ctx.Query("code")
+	cookie, err := ctx.Cookie("oidc_state")
+	ctx.SetCookie("oidc_state", "", -1, "/api/auth/oidc", config.ServerOrigin, false, true)
+	if state == "" || code == "" || err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	}
+
+	var login models.OidcLogin
+	if ac.DB.First(&login, "state_hash = ?", utils.HashToken(state)).Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	}
+	// Each state is good for one attempt only
+	deleted := ac.DB.Delete(&models.OidcLogin{}, "id = ?", login.ID)
+	if deleted.Error != nil || deleted.RowsAffected != 1 || time.Now().After(login.ExpiresAt) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": message})
+		return
+	}
+
+	tokens, err := ac.OIDC.Exchange(ctx.Request.Context(), code, login.CodeVerifier)
+	if err != nil {
+		log.Println("OIDC:", err)
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": message})
+		return
+	}
+	claims, err := ac.OIDC.VerifyIDToken(ctx.Request.Context(), tokens.IDToken, login.Nonce)
+	if err != nil {
+		log.Println("OIDC:", err)
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": message})
+		return
+	}
+
+	user, err := ac.oidcUser(ctx, &config, claims)
+	if err == errOidcEmailTaken {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": "An account with this email already exists, please log in with your password"})
+		return
+	} else if err == errOidcNoEmail {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Please allow access to your email address to sign in"})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	if user.VerificationRequired(config.EmailVerificationGracePeriod) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "Please verify your email address before logging in"})
+		return
+	}
+
+	// The provider's sign-in does not replace ours: the client asks for the
+	// code and finishes with VerifyLoginMfa as after SignInUser
+	purpose, mfaToken, err := startMfaChallenge(ac.DB, &config, &user)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+	if purpose != "" {
+		query := url.Values{"mfa_token": {mfaToken}, "purpose": {purpose}, "redirect": {login.RedirectTo}}
+		ctx.Redirect(http.StatusFound, config.ClientOrigin+"/login/mfa?"+query.Encode())
+		return
+	}
+
+	if _, err := ac.issueTokens(ctx, &config, user.ID, uuid.New()); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, config.ClientOrigin+login.RedirectTo)
+}
+
+// oidcUser returns the user signing in with claims: the one linked to the
+// provider account, else the one with the same email if the provider has
+// verified it, else a new user.
+func (ac *AuthController) oidcUser(ctx *gin.Context, config *initializers.Config, claims *oidc.Claims) (models.User, error) {
+	provider := ac.OIDC.Config.Name
+
+	var user models.User
+	var identity models.UserIdentity
+	if ac.DB.First(&identity, "provider = ? AND subject = ?", provider, claims.Subject).Error == nil {
+		err := ac.DB.First(&user, "id = ?", identity.UserId).Error
+		return user, err
+	}
+
+	email := strings.ToLower(claims.Email)
+	if email == "" {
+		return user, errOidcNoEmail
+	}
+
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.First(&user, "email = ?", email)
+		switch {
+		case result.Error == nil:
+			// Whoever controls an unverified address at the provider must not
+			// take over the account
+			if !claims.EmailVerified {
+				return errOidcEmailTaken
+			}
+			if !user.Verified {
+				user.Verified = true
+				if err := tx.Model(&user).Updates(map[string]interface{}{"verified": true, "updated_at": now}).Error; err != nil {
+					return err
+				}
+			}
+		case errors.Is(result.Error, gorm.ErrRecordNotFound):
+			username, err := availableUsername(tx, email)
+			if err != nil {
+				return err
+			}
+			name := claims.Name
+			if name == "" {
+				name, _, _ = strings.Cut(email, "@")
+			}
+			locale := mailer.NormalizeLocale(claims.Locale)
+			if locale == "" {
+				locale = mailer.PreferredLocale(ctx.GetHeader("Accept-Language"))
+			}
+			// No password: the account signs in through the provider until
+			// one is set with the forgotten password flow
+			user = models.User{
+				Name:      name,
+				Username:  username,
+				Email:     email,
+				Role:      models.RoleUser,
+				Provider:  provider,
+				Photo:     claims.Picture,
+				Verified:  claims.EmailVerified,
+				Locale:    locale,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if !user.Verified {
+				if err := ac.queueVerificationEmail(tx, config, &user); err != nil {
+					return err
+				}
+			}
+		default:
+			return result.Error
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserId:    user.ID,
+			Provider:  provider,
+			Subject:   claims.Subject,
+			Email:     email,
+			CreatedAt: now,
+		}).Error
+	})
+	return user, err
+}
+
+// availableUsername derives an unused username from an email address.
+func availableUsername(tx *gorm.DB, email string) (string, error) {
+	local, _, _ := strings.Cut(email, "@")
+	base := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return -1
+	}, local)
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		suffix, err := utils.GenerateRandomToken(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "-" + strings.ToLower(suffix)
+	}
+	return "", errors.New("could not find a free username")
+}
+
+// isClientPath reports whether p is a path on the client, and not a URL that
+// would send the user elsewhere after signing in.
+func isClientPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") && !strings.ContainsAny(p, "\\\r\n") && len(p) <= 255
+}
+
+// attempt counts a sign-in to username from this client as failed until it
+// succeeds, returning the account's failures so far. It responds with 429
+// and returns false instead when the client has to wait after earlier
+// failures.
+func (ac *AuthController) attempt(ctx *gin.Context, username string) (int, bool) {
+	wait, failures, err := ac.Logins.Attempt(username, ctx.ClientIP(), time.Now())
+	if err != nil {
+		log.Println("Failed to count sign-in attempt:", err)
+		return 0, true
+	}
+	if wait <= 0 {
+		return failures, true
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{"status": "fail", "message": fmt.Sprintf("Too many failed attempts, please try again in %d seconds", seconds)})
+	return 0, false
+}
+
+// cancelAttempt takes back an attempt that ended before checking a password
+// or code.
+func (ac *AuthController) cancelAttempt(ctx *gin.Context, username string) {
+	if err := ac.Logins.Cancel(username, ctx.ClientIP()); err != nil {
+		log.Println("Failed to take back sign-in attempt:", err)
+	}
+}
+
+func respondLocked(ctx *gin.Context, user *models.User) {
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(*user.LockedUntil).Seconds()))))
+	ctx.JSON(http.StatusLocked, gin.H{"status": "fail", "message": "This account is locked after too many failed attempts, please check your email to unlock it"})
+}
+
+// loginFailed records the failed sign-in to username that attempt already
+// counted, user being nil when no such account exists, and locks the account
+// once its failures reach the threshold.
+func (ac *AuthController) loginFailed(ctx *gin.Context, config *initializers.Config, action string, username string, user *models.User, failures int) {
+	recordAudit(ac.DB, ctx, action, username, user, nil, "")
+
+	if user == nil || config.LoginLockoutThreshold <= 0 || failures < config.LoginLockoutThreshold {
+		return
+	}
+	// Wrong passwords for a locked account don't lock it again and send
+	// another unlock email
+	if user.Locked(time.Now()) {
+		return
+	}
+	if err := ac.lockAccount(ctx, config, user, failures); err != nil {
+		log.Println("Failed to lock account:", err)
+	}
+}
+
+// lockAccount refuses sign-ins to user for the lockout duration and mails
+// them a link to unlock the account early.
+func (ac *AuthController) lockAccount(ctx *gin.Context, config *initializers.Config, user *models.User, failures int) error {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	email, err := mailer.Render("unlockaccount", user.Locale, map[string]interface{}{
+		"Name":      user.Name,
+		"Link":      config.ServerOrigin + "/api/auth/unlock/" + token,
+		"LockedFor": config.LoginLockoutDuration.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	lockedUntil := now.Add(config.LoginLockoutDuration)
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).UpdateColumn("locked_until", lockedUntil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AccountUnlock{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.AccountUnlock{
+			UserId:    user.ID,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: lockedUntil,
+			CreatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		return outbox.Enqueue(tx, user.Email, email)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The lock takes over from the counter; starting that afresh keeps the
+	// first failure after the lock ends from locking the account again
+	if err := ac.Logins.Reset(user.Username); err != nil {
+		log.Println("Failed to reset failed sign-ins:", err)
+	}
+	recordAudit(ac.DB, ctx, models.AuditAccountLocked, "", user, nil,
+		fmt.Sprintf("locked until %s after %d failed attempts", lockedUntil.Format(time.RFC3339), failures))
+	return nil
+}
+
+// issueTokens starts a new refresh token session in the given family and sets
+// the auth cookies, returning the access token.
+func (ac *AuthController) issueTokens(ctx *gin.Context, config *initializers.Config, userId uuid.UUID, familyId uuid.UUID) (string, error) {
+	return ac.issueTokensWithID(ctx, config, userId, familyId, uuid.New())
+}
+
+func (ac *AuthController) issueTokensWithID(ctx *gin.Context, config *initializers.Config, userId uuid.UUID, familyId uuid.UUID, sessionId uuid.UUID) (string, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:        sessionId,
+		UserId:    userId,
+		FamilyId:  familyId,
+		UserAgent: ctx.Request.UserAgent(),
+		ClientIP:  ctx.ClientIP(),
+		ExpiresAt: now.Add(config.RefreshTokenExpiresIn),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := ac.DB.Create(&session).Error; err != nil {
+		return "", fmt.Errorf("could not create session: %w", err)
+	}
+
+	access_token, err := ac.AccessTokens.Sign(utils.TokenClaims(config.AccessTokenExpiresIn, userId, uuid.NewString()))
+	if err != nil {
+		return "", err
+	}
+
+	refresh_token, err := utils.CreateTokenWithID(config.RefreshTokenExpiresIn, userId, session.ID.String(), config.RefreshTokenPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	ctx.SetCookie("access_token", access_token, config.AccessTokenMaxAge*60, "/", config.ServerOrigin, false, true)
+	ctx.SetCookie("refresh_token", refresh_token, config.RefreshTokenMaxAge*60, "/", config.ServerOrigin, false, true)
+	ctx.SetCookie("logged_in", "true", config.AccessTokenMaxAge*60, "/", config.ServerOrigin, false, false)
+
+	return access_token, nil
+}
+
+// queueVerificationEmail replaces any outstanding verification code for user
+// with a new one and queues the link for mailing, both within tx.
+func (ac *AuthController) queueVerificationEmail(tx *gorm.DB, config *initializers.Config, user *models.User) error {
+	code, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.EmailVerification{}).
+		Where("user_id = ? AND consumed_at IS NULL", user.ID).
+		Update("consumed_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&models.EmailVerification{
+		UserId:    user.ID,
+		CodeHash:  utils.HashToken(code),
+		ExpiresAt: now.Add(config.EmailVerificationExpiresIn),
+		CreatedAt: now,
+	}).Error; err != nil {
+		return fmt.Errorf("could not create verification code: %w", err)
+	}
+
+	email, err := mailer.Render("verify", user.Locale, map[string]interface{}{
+		"Name": user.Name,
+		"Link": config.ServerOrigin + "/api/auth/verifyemail/" + code,
+	})
+	if err != nil {
+		return err
+	}
+
+	return outbox.Enqueue(tx, user.Email, email)
+}
+
+func (ac *AuthController) revokeSessionFamily(familyId uuid.UUID) {
+	now := time.Now()
+	result := ac.DB.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Updates(map[string]interface{}{"revoked_at": now, "updated_at": now})
+	if result.Error != nil {
+		log.Println("Failed to revoke session family:", result.Error)
+	}
+}
+
+func (ac *AuthController) clearAuthCookies(ctx *gin.Context, config *initializers.Config) {
 	ctx.SetCookie("access_token", "", -1, "/", config.ServerOrigin, false, true)
 	ctx.SetCookie("refresh_token", "", -1, "/", config.ServerOrigin, false, true)
 	ctx.SetCookie("logged_in", "", -1, "/", config.ServerOrigin, false, false)
-
-	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
 }
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/mailer"
+)
+
+// DevController serves helpers that only exist in development.
+type DevController struct {
+	Mail mailer.Transport
+}
+
+func NewDevController(Mail mailer.Transport) DevController {
+	return DevController{Mail}
+}
+
+// FindEmailTemplates lists the emails that can be previewed.
+func (dc *DevController) FindEmailTemplates(ctx *gin.Context) {
+	names, err := mailer.Default.Names()
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(names), "data": names})
+}
+
+// PreviewEmail renders an email with its sample data. ?locale= picks a
+// translation and ?format=text shows the plain-text part instead of the HTML.
+func (dc *DevController) PreviewEmail(ctx *gin.Context) {
+	name := ctx.Param("name")
+
+	sample, err := mailer.Default.Sample(name)
+	if err == nil {
+		var email mailer.Email
+		email, err = mailer.Default.Render(name, ctx.Query("locale"), sample)
+		if err == nil {
+			ctx.Header("X-Email-Subject", email.Subject)
+			if ctx.Query("format") == "text" {
+				ctx.String(http.StatusOK, email.Text)
+			} else {
+				ctx.Header("Content-Type", "text/html; charset=utf-8")
+				ctx.String(http.StatusOK, email.HTML)
+			}
+			return
+		}
+	}
+
+	if errors.Is(err, mailer.ErrTemplateNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+}
+
+// FindMail lists the email captured by the file or memory mail driver, oldest
+// first. ?to= only returns the email sent to that address.
+func (dc *DevController) FindMail(ctx *gin.Context) {
+	mailbox, ok := dc.Mail.(mailer.Mailbox)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "The mail driver does not capture email"})
+		return
+	}
+
+	messages, err := mailbox.Messages()
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	if to := ctx.Query("to"); to != "" {
+		filtered := messages[:0]
+		for _, message := range messages {
+			if strings.EqualFold(message.To, to) {
+				filtered = append(filtered, message)
+			}
+		}
+		messages = filtered
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(messages), "data": messages})
+}
+
+// ClearMail forgets all captured email.
+func (dc *DevController) ClearMail(ctx *gin.Context) {
+	mailbox, ok := dc.Mail.(mailer.Mailbox)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "The mail driver does not capture email"})
+		return
+	}
+
+	if err := mailbox.Clear(); err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
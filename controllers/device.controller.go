@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/utils"
+	"gorm.io/gorm"
+)
+
+// deviceKeyPrefix marks station device keys so that leaked ones are easy to
+// recognise.
+const deviceKeyPrefix = "ottbd_"
+
+// maxHeartbeatLimit caps how many heartbeats one listing returns.
+const maxHeartbeatLimit = 500
+
+type DeviceController struct {
+	DB *gorm.DB
+}
+
+func NewDeviceController(DB *gorm.DB) DeviceController {
+	return DeviceController{DB}
+}
+
+// CreateStationDevice registers a device for a station and returns its API
+// key. The key is only ever shown in this response.
+func (dc *DeviceController) CreateStationDevice(ctx *gin.Context) {
+	stationId := ctx.Param("stationId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.CreateStationDeviceInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	var station models.Station
+	if dc.DB.First(&station, "id = ?", stationId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
+		return
+	}
+
+	id, err := utils.GenerateRandomToken(6)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	prefix := deviceKeyPrefix + id
+	key := prefix + "." + secret
+
+	device := models.StationDevice{
+		StationId: station.ID,
+		Name:      payload.Name,
+		KeyPrefix: prefix,
+		KeyHash:   utils.HashToken(key),
+		CreatedBy: currentUser.ID,
+		CreatedAt: time.Now(),
+	}
+	if err := dc.DB.Create(&device).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": gin.H{"device": device, "key": key}})
+}
+
+// FindStationDevices lists a station's devices, revoked ones included.
+func (dc *DeviceController) FindStationDevices(ctx *gin.Context) {
+	stationId := ctx.Param("stationId")
+
+	var devices []models.StationDevice
+	results := dc.DB.Where("station_id = ?", stationId).Order("created_at").Find(&devices)
+	if results.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(devices), "data": devices})
+}
+
+// RevokeStationDevice stops a device's key from working.
+func (dc *DeviceController) RevokeStationDevice(ctx *gin.Context) {
+	stationId := ctx.Param("stationId")
+	deviceId := ctx.Param("deviceId")
+
+	result := dc.DB.Model(&models.StationDevice{}).
+		Where("id = ? AND station_id = ? AND revoked_at IS NULL", deviceId, stationId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil || result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No active device with that ID exists"})
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+// RecordHeartbeat stores a status report from the current device, or from an
+// API key whose user may modify the station, and marks the station as seen,
+// and back online if it was not.
+func (dc *DeviceController) RecordHeartbeat(ctx *gin.Context) {
+	stationId := ctx.Param("stationId")
+
+	var station models.Station
+	if dc.DB.First(&station, "id = ?", stationId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
+		return
+	}
+
+	heartbeat := models.StationHeartbeat{StationId: station.ID}
+	var device *models.StationDevice
+	if value, ok := ctx.Get("currentDevice"); ok {
+		current := value.(models.StationDevice)
+		if current.StationId != station.ID {
+			ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "This device does not belong to that station"})
+			return
+		}
+		device = &current
+		heartbeat.DeviceId = &current.ID
+	} else {
+		apiKey := ctx.MustGet("currentApiKey").(models.ApiKey)
+		currentUser := ctx.MustGet("currentUser").(models.User)
+		if !canModifyStation(&currentUser, &station) {
+			ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to modify this station"})
+			return
+		}
+		heartbeat.ApiKeyId = &apiKey.ID
+	}
+
+	var payload *models.HeartbeatInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	heartbeat.PaperLevel = payload.PaperLevel
+	heartbeat.FilmLevel = payload.FilmLevel
+	heartbeat.DiskFreeBytes = payload.DiskFreeBytes
+	heartbeat.DiskTotalBytes = payload.DiskTotalBytes
+	heartbeat.Version = payload.Version
+	heartbeat.ReceivedAt = now
+	err := dc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&heartbeat).Error; err != nil {
+			return err
+		}
+		if device != nil {
+			if err := tx.Model(device).UpdateColumn("last_seen_at", now).Error; err != nil {
+				return err
+			}
+		}
+		// Columns only, so that heartbeats do not count as edits of the station
+		return tx.Model(&station).UpdateColumns(map[string]interface{}{
+			"last_seen_at":  now,
+			"offline_since": nil,
+		}).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": heartbeat})
+}
+
+// FindStationHeartbeats lists a station's latest heartbeats, newest first.
+func (dc *DeviceController) FindStationHeartbeats(ctx *gin.Context) {
+	stationId := ctx.Param("stationId")
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > maxHeartbeatLimit {
+		limit = maxHeartbeatLimit
+	}
+
+	var heartbeats []models.StationHeartbeat
+	results := dc.DB.Where("station_id = ?", stationId).Order("received_at DESC").Limit(limit).Find(&heartbeats)
+	if results.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(heartbeats), "data": heartbeats})
+}
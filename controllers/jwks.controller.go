@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/keyring"
+)
+
+type JWKSController struct {
+	AccessTokens *keyring.Ring
+}
+
+func NewJWKSController(AccessTokens *keyring.Ring) JWKSController {
+	return JWKSController{AccessTokens}
+}
+
+// FindKeys publishes the public keys access tokens are signed with, in the
+// JSON Web Key Set format other services verify them with.
+func (jc *JWKSController) FindKeys(ctx *gin.Context) {
+	// Caches must pick up the next key before it starts signing, which is one
+	// overlap after it is first published
+	maxAge := jc.AccessTokens.Overlap() / 2
+	if maxAge > 5*time.Minute {
+		maxAge = 5 * time.Minute
+	}
+	ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	ctx.JSON(http.StatusOK, jc.AccessTokens.JWKS(time.Now()))
+}
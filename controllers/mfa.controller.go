@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mliem2k/ottb-go/initializers"
+	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user gets at a time.
+	recoveryCodeCount = 10
+	// maxMfaAttempts is how many codes may be tried against one challenge.
+	maxMfaAttempts = 5
+)
+
+type MfaController struct {
+	DB *gorm.DB
+}
+
+func NewMfaController(DB *gorm.DB) MfaController {
+	return MfaController{DB}
+}
+
+// StartMfaEnrollment gives the current user a new TOTP secret to add to an
+// authenticator app. It only takes effect once ConfirmMfaEnrollment accepts
+// a code for it.
+func (mc *MfaController) StartMfaEnrollment(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	config, _ := initializers.LoadConfig(".")
+
+	if currentUser.MfaEnabled() {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	enrollment, err := startTotpEnrollment(mc.DB, &config, &currentUser)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": enrollment})
+}
+
+// ConfirmMfaEnrollment turns two-factor authentication on once the user
+// proves their app works, and returns their recovery codes.
+func (mc *MfaController) ConfirmMfaEnrollment(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.MfaCodeInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if currentUser.MfaEnabled() {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": "Two-factor authentication is already enabled"})
+		return
+	}
+	if currentUser.MfaSecret == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Start setting up two-factor authentication first"})
+		return
+	}
+	if !acceptTotp(mc.DB, &currentUser, payload.Code) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	err := mc.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = enableMfa(tx, &currentUser)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"recovery_codes": codes}})
+}
+
+// DisableMfa turns two-factor authentication off, given a current code,
+// unless the user's role requires it.
+func (mc *MfaController) DisableMfa(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	config, _ := initializers.LoadConfig(".")
+
+	var payload *models.MfaCodeInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if !currentUser.MfaEnabled() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Two-factor authentication is not enabled"})
+		return
+	}
+	if mfaRequired(&config, &currentUser) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "Your role requires two-factor authentication"})
+		return
+	}
+	if !acceptTotp(mc.DB, &currentUser, payload.Code) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid code"})
+		return
+	}
+
+	if err := disableMfa(mc.DB, currentUser.ID); err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes, given
+// a current code.
+func (mc *MfaController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.MfaCodeInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if !currentUser.MfaEnabled() {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Two-factor authentication is not enabled"})
+		return
+	}
+	if !acceptTotp(mc.DB, &currentUser, payload.Code) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	err := mc.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, currentUser.ID)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"recovery_codes": codes}})
+}
+
+// ResetUserMfa lets an admin turn off two-factor authentication for a user
+// who lost their device and recovery codes. Users whose role requires it
+// set it up again on their next sign-in.
+func (mc *MfaController) ResetUserMfa(ctx *gin.Context) {
+	userId := ctx.Param("userId")
+
+	var user models.User
+	if mc.DB.First(&user, "id = ?", userId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No user with that ID exists"})
+		return
+	}
+
+	if err := disableMfa(mc.DB, user.ID); err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+// mfaRequired reports whether the user's role is one that must use
+// two-factor authentication.
+func mfaRequired(config *initializers.Config, user *models.User) bool {
+	for _, role := range strings.Split(config.MfaRequiredRoles, ",") {
+		if strings.TrimSpace(role) == user.Role {
+			return true
+		}
+	}
+	return false
+}
+
+// startMfaChallenge starts the second step of a sign-in if the user needs
+// one, returning its purpose and token; the purpose is empty otherwise.
+func startMfaChallenge(db *gorm.DB, config *initializers.Config, user *models.User) (string, string, error) {
+	purpose := ""
+	switch {
+	case user.MfaEnabled():
+		purpose = models.MfaPurposeLogin
+	case mfaRequired(config, user):
+		purpose = models.MfaPurposeEnroll
+	default:
+		return "", "", nil
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	db.Where("expires_at < ?", now).Delete(&models.MfaChallenge{})
+	err = db.Create(&models.MfaChallenge{
+		UserId:    user.ID,
+		TokenHash: utils.HashToken(token),
+		Purpose:   purpose,
+		ExpiresAt: now.Add(config.MfaChallengeExpiresIn),
+		CreatedAt: now,
+	}).Error
+	return purpose, token, err
+}
+
+// startTotpEnrollment stores a new pending TOTP secret for user and returns
+// what an authenticator app needs to add it.
+func startTotpEnrollment(db *gorm.DB, config *initializers.Config, user *models.User) (gin.H, error) {
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Model(user).UpdateColumns(map[string]interface{}{
+		"mfa_secret":       secret,
+		"mfa_last_counter": 0,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(config.MfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// acceptTotp checks a code from the user's authenticator app and records its
+// time step, so the same code is refused the next time.
+func acceptTotp(db *gorm.DB, user *models.User, code string) bool {
+	if user.MfaSecret == "" {
+		return false
+	}
+	counter, ok := utils.ValidateTOTP(user.MfaSecret, code, time.Now())
+	if !ok {
+		return false
+	}
+
+	result := db.Model(&models.User{}).
+		Where("id = ? AND mfa_last_counter < ?", user.ID, counter).
+		UpdateColumn("mfa_last_counter", counter)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// useRecoveryCode spends one of the user's recovery codes.
+func useRecoveryCode(db *gorm.DB, user *models.User, code string) bool {
+	result := db.Model(&models.MfaRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// enableMfa turns on two-factor authentication with the user's pending
+// secret and returns their first recovery codes.
+func enableMfa(tx *gorm.DB, user *models.User) ([]string, error) {
+	now := time.Now()
+	if err := tx.Model(user).UpdateColumn("mfa_enabled_at", now).Error; err != nil {
+		return nil, err
+	}
+	user.MfaEnabledAt = &now
+	return replaceRecoveryCodes(tx, user.ID)
+}
+
+func disableMfa(db *gorm.DB, userId uuid.UUID) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&models.MfaRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userId).UpdateColumns(map[string]interface{}{
+			"mfa_secret":       "",
+			"mfa_enabled_at":   nil,
+			"mfa_last_counter": 0,
+		}).Error
+	})
+}
+
+// replaceRecoveryCodes throws away a user's recovery codes and returns new
+// ones; only their hashes are kept.
+func replaceRecoveryCodes(tx *gorm.DB, userId uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userId).Delete(&models.MfaRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.MfaRecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := base32.StdEncoding.EncodeToString(b)
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		records[i] = models.MfaRecoveryCode{
+			UserId:    userId,
+			CodeHash:  utils.HashToken(raw),
+			CreatedAt: now,
+		}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores the dashes, spaces and case a user may type.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
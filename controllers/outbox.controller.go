@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/outbox"
+	"gorm.io/gorm"
+)
+
+type OutboxController struct {
+	DB *gorm.DB
+}
+
+func NewOutboxController(DB *gorm.DB) OutboxController {
+	return OutboxController{DB}
+}
+
+// FindOutboxMessages lists queued email, newest first, optionally filtered by
+// status, e.g. ?status=dead for the dead letters.
+func (oc *OutboxController) FindOutboxMessages(ctx *gin.Context) {
+	page := ctx.DefaultQuery("page", "1")
+	limit := ctx.DefaultQuery("limit", "10")
+	status := ctx.Query("status")
+
+	intPage, _ := strconv.Atoi(page)
+	intLimit, _ := strconv.Atoi(limit)
+	offset := (intPage - 1) * intLimit
+
+	query := oc.DB.Model(&models.OutboxMessage{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	var messages []models.OutboxMessage
+	results := query.Order("created_at DESC").Limit(intLimit).Offset(offset).Find(&messages)
+	if results.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "page": page, "results": len(messages), "total_results": total, "data": messages})
+}
+
+// RetryOutboxMessage queues a failed or dead message to be sent again now.
+func (oc *OutboxController) RetryOutboxMessage(ctx *gin.Context) {
+	messageId := ctx.Param("messageId")
+
+	ok, err := outbox.Retry(oc.DB, messageId)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No unsent message with that ID exists"})
+		return
+	}
+
+	var message models.OutboxMessage
+	oc.DB.First(&message, "id = ?", messageId)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": message})
+}
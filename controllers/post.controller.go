@@ -1,11 +1,10 @@
 package controllers
 
 import (
-	"fmt"
-	"io"
+	"errors"
+	"log"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -13,37 +12,38 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/mliem2k/ottb-go/initializers"
+	"github.com/mliem2k/ottb-go/mailer"
 	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/outbox"
+	"github.com/mliem2k/ottb-go/upload"
 	"gorm.io/gorm"
 )
 
+var errPostStatusChanged = errors.New("the post's status was changed by someone else, please reload it")
+
 type PostController struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Uploads *upload.Service
 }
 
-func NewPostController(DB *gorm.DB) PostController {
-	return PostController{DB}
+func NewPostController(DB *gorm.DB, Uploads *upload.Service) PostController {
+	return PostController{DB, Uploads}
 }
 
 func (pc *PostController) CreatePost(ctx *gin.Context) {
-	// currentUser := ctx.MustGet("currentUser").(models.User)
+	currentUser := ctx.MustGet("currentUser").(models.User)
 
 	// Parse form data
-	err := ctx.Request.ParseMultipartForm(10 << 20) // 10 MB max
-
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Failed to parse form data"})
+	if err := pc.Uploads.ParseMultipartForm(ctx.Writer, ctx.Request); err != nil {
+		respondUploadError(ctx, err)
 		return
 	}
 
 	// Extract other fields from the form
 	title := ctx.Request.FormValue("title")
-	user := ctx.Request.FormValue("user")
-
-	parsedUser, err := uuid.Parse(user)
 
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid user ID"})
+	parsedUser, ok := postOwner(ctx, &currentUser, ctx.Request.FormValue("user"), currentUser.ID)
+	if !ok {
 		return
 	}
 	stationId := ctx.Request.FormValue("stationId")
@@ -59,8 +59,7 @@ func (pc *PostController) CreatePost(ctx *gin.Context) {
 	newPost := models.Post{
 		Title: title,
 		// Content:   content,
-		// Image:     strings.Join(imageNames, ","),
-		Developed: false,
+		Status:    models.PostStatusShot,
 		UserId:    parsedUser,
 		StationId: parsedStationId,
 		CreatedAt: now,
@@ -79,68 +78,41 @@ func (pc *PostController) CreatePost(ctx *gin.Context) {
 
 func (pc *PostController) UpdatePost(ctx *gin.Context) {
 	postId := ctx.Param("postId")
-	// currentUser := ctx.MustGet("currentUser").(models.User)
-	err := ctx.Request.ParseMultipartForm(10 << 20) // 10 MB max
-
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Failed to parse form data"})
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	if err := pc.Uploads.ParseMultipartForm(ctx.Writer, ctx.Request); err != nil {
+		respondUploadError(ctx, err)
 		return
 	}
-	user := ctx.Request.FormValue("user")
 	title := ctx.Request.FormValue("title")
 	// image := ctx.Request.FormValue("image")
-	developedStr := ctx.Request.FormValue("developed")
-	developed, _ := strconv.ParseBool(developedStr)
 
-	parsedUser, err := uuid.Parse(user)
+	var postModel models.Post
+	result := pc.DB.First(&postModel, "id = ?", postId)
+	if result.Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
+		return
+	}
+	if !canAccessPost(&currentUser, &postModel) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to modify this post"})
+		return
+	}
 
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid user ID"})
+	parsedUser, ok := postOwner(ctx, &currentUser, ctx.Request.FormValue("user"), postModel.UserId)
+	if !ok {
 		return
 	}
-	// Handle file uploads
-	files := ctx.Request.MultipartForm.File["image"]
-	var imageNames []string
-	for _, fileHeader := range files {
-		file, err := fileHeader.Open()
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Failed to get image file"})
-			return
-		}
-		defer file.Close()
-
-		// Save uploaded file to server with timestamp
-		currentTime := time.Now().UnixNano()
-		imageExtension := filepath.Ext(fileHeader.Filename)
-		imageName := fmt.Sprintf("%d%s", currentTime, imageExtension)
-		filePath := "uploads/" + imageName
-		outFile, err := os.Create(filePath)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to save image file"})
-			return
-		}
-		defer outFile.Close()
-		_, err = io.Copy(outFile, file)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to save image file"})
-			return
-		}
-		imageNames = append(imageNames, imageName)
+
+	// Uploaded images are added after the ones the post already has.
+	if _, err := pc.appendImages(ctx, &postModel, ctx.Request.MultipartForm.File["image"]); err != nil {
+		respondUploadError(ctx, err)
+		return
 	}
+
 	// var payload *models.UpdatePost
-	var postModel models.Post
 	now := time.Now()
 
-	result := pc.DB.First(&postModel, "id = ?", postId)
-	if result.Error != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
-		return
-	}
-	// fmt.Println(result.)
 	postToUpdate := map[string]interface{}{
 		"title":      title,
-		"image":      strings.Join(imageNames, ","),
-		"developed":  developed,
 		"user_id":    parsedUser,
 		"created_at": postModel.CreatedAt,
 		"updated_at": now,
@@ -153,153 +125,610 @@ func (pc *PostController) UpdatePost(ctx *gin.Context) {
 	}
 	pc.DB.Debug().Model(&postModel).Updates(postToUpdate)
 
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": postToUpdate})
+	pc.DB.Preload("Images", orderedImages).Preload("Images.Variants").First(&postModel, "id = ?", postModel.ID)
+	pc.setImageURLs(&postModel)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": postModel})
 }
 
 func (pc *PostController) FindPostById(ctx *gin.Context) {
 	postId := ctx.Param("postId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
 
 	var post models.Post
-	result := pc.DB.First(&post, "id = ?", postId)
+	result := pc.DB.Preload("Images", orderedImages).Preload("Images.Variants").Preload("History", orderedHistory).First(&post, "id = ?", postId)
 	if result.Error != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
 		return
 	}
+	if !canAccessPost(&currentUser, &post) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to view this post"})
+		return
+	}
+	pc.setImageURLs(&post)
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": post})
 }
 
 func (pc *PostController) FindPostsByUserId(ctx *gin.Context) {
-	config, err := initializers.LoadConfig(".")
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to load config"})
-		return
-	}
 
 	userId := ctx.Param("userId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	if !currentUser.IsStaff() && currentUser.ID.String() != userId {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to view these posts"})
+		return
+	}
 
 	var posts []models.Post
-	result := pc.DB.Where("user_id = ? AND image <> '' AND developed = ?", userId, true).Find(&posts)
+	result := pc.DB.
+		Preload("Images", orderedImages).
+		Preload("Images.Variants").
+		Where("user_id = ? AND status IN ?", userId, []string{models.PostStatusDeveloped, models.PostStatusDelivered}).
+		Where("EXISTS (SELECT 1 FROM post_images WHERE post_images.post_id = posts.id)").
+		Find(&posts)
 
 	if result.Error != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to find posts"})
 		return
 	}
 
-	// Create a new JSON structure without using struct images
+	// Flatten the posts into one entry per image
 	var responseData []map[string]interface{}
-	for _, post := range posts {
-		images := strings.Split(post.Image, ",")
-		var formattedImages []string
-		for _, image := range images {
-			formattedImages = append(formattedImages, config.ServerOrigin+"/uploads/"+image)
-		}
-
-		responseData = append(responseData, map[string]interface{}{
-			"id":         post.ID,
-			"title":      post.Title,
-			"images":     formattedImages,
-			"user_id":    post.UserId,
-			"created_at": post.CreatedAt.Format(time.RFC3339),
-			"updated_at": post.UpdatedAt.Format(time.RFC3339),
-		})
-	}
-	var updatedResponseData []map[string]interface{}
-	for _, data := range responseData {
-		// Extract the images slice
-		images, ok := data["images"].([]string)
-		if !ok {
-			// If the `images` key is not present or not a []string, skip this item
-			continue
-		}
-		// Iterate over each image URL
-		for _, img := range images {
-			// Create a new map for each image URL
-			newData := map[string]interface{}{
-				"id":         data["id"],
-				"title":      data["title"],
-				"image":      img, // Set the image URL
-				"user_id":    data["user_id"],
-				"created_at": data["created_at"],
-				"updated_at": data["updated_at"],
-			}
-
-			// Append the new map to the updated response data slice
-			updatedResponseData = append(updatedResponseData, newData)
+	for i := range posts {
+		post := &posts[i]
+		pc.setImageURLs(post)
+		for _, image := range post.Images {
+			responseData = append(responseData, map[string]interface{}{
+				"id":         post.ID,
+				"title":      post.Title,
+				"image_id":   image.ID,
+				"image":      image.URL,
+				"variants":   image.VariantURLs,
+				"user_id":    post.UserId,
+				"created_at": post.CreatedAt.Format(time.RFC3339),
+				"updated_at": post.UpdatedAt.Format(time.RFC3339),
+			})
 		}
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": updatedResponseData})
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": responseData})
 }
 
 func (pc *PostController) FindPosts(ctx *gin.Context) {
-	config, err := initializers.LoadConfig(".")
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to load config"})
-		return
-	}
+	currentUser := ctx.MustGet("currentUser").(models.User)
 
 	// Get query parameters
 	page := ctx.DefaultQuery("page", "1")
 	limit := ctx.DefaultQuery("limit", "10")
-	developed := ctx.Query("developed")
+	status := ctx.Query("status")
 
 	// Convert page and limit to integers
 	intPage, _ := strconv.Atoi(page)
 	intLimit, _ := strconv.Atoi(limit)
 	offset := (intPage - 1) * intLimit
 
-	var posts []map[string]interface{}       // Using a map to store posts and station_name
-	var posts_total []map[string]interface{} // Using a map to store posts and station_name
-
 	baseQuery := pc.DB.Debug().
-		Table("posts").
-		Select("posts.*, stations.name as station_name").
+		Model(&models.Post{}).
 		Joins("JOIN stations ON posts.station_id::uuid = stations.id")
 
-	// Apply pagination
-	baseQueryTotal := baseQuery.Find(&posts_total)
-	baseQueryLO := baseQuery.Limit(intLimit).Offset(offset)
+	// Customers only ever see their own rolls; staff see everyone's.
+	if !currentUser.IsStaff() {
+		baseQuery = baseQuery.Where("posts.user_id = ?", currentUser.ID)
+	}
 
-	if baseQueryTotal.Error != nil {
-		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": baseQueryTotal.Error})
-		return
+	// Apply additional filter if "status" parameter is present; several
+	// states can be given separated by commas.
+	if status != "" {
+		statuses := strings.Split(status, ",")
+		for _, s := range statuses {
+			if !models.ValidPostStatus(s) {
+				ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Unknown post status: " + s})
+				return
+			}
+		}
+		baseQuery = baseQuery.Where("posts.status IN ?", statuses)
 	}
+	baseQuery = baseQuery.Session(&gorm.Session{})
 
-	// Apply additional filter if "developed" parameter is present
-	if developed != "" {
-		baseQueryLO = baseQueryLO.Where("posts.developed = ?", developed)
+	var total int64
+	if err := baseQuery.Count(&total).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err})
+		return
 	}
 
-	// Execute the query
-	results := baseQueryLO.Find(&posts)
+	// Apply pagination and execute the query
+	var posts []models.Post
+	results := baseQuery.
+		Select("posts.*, stations.name as station_name").
+		Preload("Images", orderedImages).
+		Preload("Images.Variants").
+		Order("posts.created_at DESC").
+		Limit(intLimit).
+		Offset(offset).
+		Find(&posts)
 
 	// Check for errors in query execution
 	if results.Error != nil {
 		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error})
 		return
 	}
-	for _, img := range posts {
-		images := strings.Split(img["image"].(string), ",")
-		var formattedImages []string
-		for _, image := range images {
-			formattedImages = append(formattedImages, config.ServerOrigin+"/uploads/"+image)
-		}
-		img["image"] = formattedImages
+	for i := range posts {
+		pc.setImageURLs(&posts[i])
 	}
 	// Return JSON response with results
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "page": page, "results": len(posts), "total_results": len(posts_total), "data": posts})
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "page": page, "results": len(posts), "total_results": total, "data": posts})
 }
 
 func (pc *PostController) DeletePost(ctx *gin.Context) {
 	postId := ctx.Param("postId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
 
-	result := pc.DB.Delete(&models.Post{}, "id = ?", postId)
+	var post models.Post
+	if pc.DB.Preload("Images.Variants").First(&post, "id = ?", postId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
+		return
+	}
+	if !canAccessPost(&currentUser, &post) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to delete this post"})
+		return
+	}
 
-	if result.Error != nil {
+	var result *gorm.DB
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		imageIds := tx.Model(&models.PostImage{}).Select("id").Where("post_id = ?", post.ID)
+		if err := tx.Where("post_image_id IN (?)", imageIds).Delete(&models.PostImageVariant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", post.ID).Delete(&models.PostImage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id = ?", post.ID).Delete(&models.PostStatusHistory{}).Error; err != nil {
+			return err
+		}
+		result = tx.Delete(&models.Post{}, "id = ?", postId)
+		return result.Error
+	})
+
+	if err != nil || result.RowsAffected == 0 {
 		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
 		return
 	}
 
+	for _, image := range post.Images {
+		pc.removeImageFiles(&image)
+	}
+
 	ctx.JSON(http.StatusNoContent, nil)
 }
+
+// TransitionPost moves a post to another development status, recording who
+// moved it in the post's history.
+func (pc *PostController) TransitionPost(ctx *gin.Context) {
+	postId := ctx.Param("postId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.TransitionPostInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if !models.ValidPostStatus(payload.Status) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Unknown post status: " + payload.Status})
+		return
+	}
+
+	var post models.Post
+	if pc.DB.Preload("Images", orderedImages).Preload("Images.Variants").First(&post, "id = ?", postId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
+		return
+	}
+	pc.setImageURLs(&post)
+	if !models.CanTransitionPostStatus(post.Status, payload.Status) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": "A post cannot move from " + post.Status + " to " + payload.Status})
+		return
+	}
+
+	now := time.Now()
+	entry := models.PostStatusHistory{
+		PostId:     post.ID,
+		FromStatus: post.Status,
+		ToStatus:   payload.Status,
+		ChangedBy:  currentUser.ID,
+		Note:       payload.Note,
+		CreatedAt:  now,
+	}
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		// Only move the post if nobody changed its status in the meantime.
+		result := tx.Model(&models.Post{}).
+			Where("id = ? AND status = ?", post.ID, post.Status).
+			Updates(map[string]interface{}{"status": payload.Status, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errPostStatusChanged
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		if payload.Status == models.PostStatusDeveloped {
+			return pc.queueDevelopedEmail(tx, &post)
+		}
+		return nil
+	})
+	if errors.Is(err, errPostStatusChanged) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": err.Error()})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	pc.DB.Preload("Images", orderedImages).Preload("Images.Variants").Preload("History", orderedHistory).First(&post, "id = ?", post.ID)
+	pc.setImageURLs(&post)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": post})
+}
+
+// queueDevelopedEmail queues an email within tx telling the owner of post,
+// unless they opted out, that their photos are ready. post must have its image
+// URLs set.
+func (pc *PostController) queueDevelopedEmail(tx *gorm.DB, post *models.Post) error {
+	config, _ := initializers.LoadConfig(".")
+
+	var owner models.User
+	if err := tx.First(&owner, "id = ?", post.UserId).Error; err != nil {
+		return err
+	}
+	if !owner.NotifyOnDeveloped {
+		return nil
+	}
+
+	var thumbnails []string
+	for _, image := range post.Images {
+		thumbnails = append(thumbnails, image.VariantURLs["thumbnail"])
+	}
+
+	email, err := mailer.Render("postdeveloped", owner.Locale, map[string]interface{}{
+		"Name":       owner.Name,
+		"Title":      post.Title,
+		"Thumbnails": thumbnails,
+		"Link":       config.ClientOrigin + "/posts/" + post.ID.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return outbox.Enqueue(tx, owner.Email, email)
+}
+
+// FindPostTransitions lists a post's status changes, oldest first.
+func (pc *PostController) FindPostTransitions(ctx *gin.Context) {
+	postId := ctx.Param("postId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var post models.Post
+	if pc.DB.First(&post, "id = ?", postId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
+		return
+	}
+	if !canAccessPost(&currentUser, &post) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to view this post"})
+		return
+	}
+
+	var history []models.PostStatusHistory
+	if err := orderedHistory(pc.DB).Where("post_id = ?", post.ID).Find(&history).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(history), "data": history})
+}
+
+// AddPostImages appends the uploaded "image" files to the end of a post.
+func (pc *PostController) AddPostImages(ctx *gin.Context) {
+	postId := ctx.Param("postId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	if err := pc.Uploads.ParseMultipartForm(ctx.Writer, ctx.Request); err != nil {
+		respondUploadError(ctx, err)
+		return
+	}
+
+	var post models.Post
+	if pc.DB.First(&post, "id = ?", postId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
+		return
+	}
+	if !canAccessPost(&currentUser, &post) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to modify this post"})
+		return
+	}
+
+	files := ctx.Request.MultipartForm.File["image"]
+	if len(files) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "No image files were uploaded"})
+		return
+	}
+
+	images, err := pc.appendImages(ctx, &post, files)
+	if err != nil {
+		respondUploadError(ctx, err)
+		return
+	}
+	for i := range images {
+		pc.setImageURL(&images[i])
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": images})
+}
+
+// ReorderPostImages sets the display order of a post's images. The payload
+// must list every image of the post exactly once.
+func (pc *PostController) ReorderPostImages(ctx *gin.Context) {
+	postId := ctx.Param("postId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.ReorderPostImagesInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	var post models.Post
+	if pc.DB.Preload("Images").First(&post, "id = ?", postId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
+		return
+	}
+	if !canAccessPost(&currentUser, &post) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to modify this post"})
+		return
+	}
+
+	existing := make(map[uuid.UUID]bool, len(post.Images))
+	for _, image := range post.Images {
+		existing[image.ID] = true
+	}
+	if len(payload.ImageIds) != len(existing) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "image_ids must list every image of the post exactly once"})
+		return
+	}
+	for _, id := range payload.ImageIds {
+		if !existing[id] {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "image_ids must list every image of the post exactly once"})
+			return
+		}
+		delete(existing, id)
+	}
+
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		for position, id := range payload.ImageIds {
+			if err := tx.Model(&models.PostImage{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	pc.DB.Preload("Images", orderedImages).Preload("Images.Variants").First(&post, "id = ?", post.ID)
+	pc.setImageURLs(&post)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": post.Images})
+}
+
+// DeletePostImage removes one image from a post and closes the gap it leaves
+// in the ordering.
+func (pc *PostController) DeletePostImage(ctx *gin.Context) {
+	postId := ctx.Param("postId")
+	imageId := ctx.Param("imageId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var post models.Post
+	if pc.DB.First(&post, "id = ?", postId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No post with that title exists"})
+		return
+	}
+	if !canAccessPost(&currentUser, &post) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to modify this post"})
+		return
+	}
+
+	var image models.PostImage
+	if pc.DB.Preload("Variants").First(&image, "id = ? AND post_id = ?", imageId, post.ID).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No image with that ID exists on this post"})
+		return
+	}
+
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_image_id = ?", image.ID).Delete(&models.PostImageVariant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&image).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PostImage{}).
+			Where("post_id = ? AND position > ?", post.ID, image.Position).
+			Update("position", gorm.Expr("position - 1")).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	pc.removeImageFiles(&image)
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+// appendImages stores files and records them after the post's existing
+// images.
+func (pc *PostController) appendImages(ctx *gin.Context, post *models.Post, files []*multipart.FileHeader) ([]models.PostImage, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	stored, err := pc.Uploads.SaveAll(ctx.Request.Context(), "posts/", files)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([][]upload.Variant, len(stored))
+	cleanup := func() {
+		pc.Uploads.DeleteAll(stored)
+		for _, saved := range variants {
+			for _, variant := range saved {
+				pc.Uploads.Delete(variant.Key)
+			}
+		}
+	}
+
+	for i, file := range stored {
+		variants[i], err = pc.Uploads.SaveVariants(ctx.Request.Context(), file, upload.PostVariants)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	var images []models.PostImage
+	err = pc.DB.Transaction(func(tx *gorm.DB) error {
+		var next int
+		if err := tx.Model(&models.PostImage{}).
+			Where("post_id = ?", post.ID).
+			Select("COALESCE(MAX(position) + 1, 0)").
+			Scan(&next).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i, file := range stored {
+			image := models.PostImage{
+				PostId:    post.ID,
+				Position:  next,
+				Filename:  file.Key,
+				Size:      file.Size,
+				MimeType:  file.MimeType,
+				Width:     file.Width,
+				Height:    file.Height,
+				Checksum:  file.Checksum,
+				CreatedAt: now,
+
+				TakenAt:      file.Metadata.TakenAt,
+				CameraMake:   file.Metadata.CameraMake,
+				CameraModel:  file.Metadata.CameraModel,
+				Orientation:  file.Metadata.Orientation,
+				GPSLatitude:  file.Metadata.Latitude,
+				GPSLongitude: file.Metadata.Longitude,
+			}
+			for _, variant := range variants[i] {
+				image.Variants = append(image.Variants, models.PostImageVariant{
+					Name:      variant.Name,
+					Filename:  variant.Key,
+					Size:      variant.Size,
+					MimeType:  variant.MimeType,
+					Width:     variant.Width,
+					Height:    variant.Height,
+					CreatedAt: now,
+				})
+			}
+			if err := tx.Create(&image).Error; err != nil {
+				return err
+			}
+			images = append(images, image)
+			next++
+		}
+		return nil
+	})
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// setImageURLs fills in the public URLs of every image of post. Sizes that
+// have no variant, because the original is already smaller or could not be
+// decoded, point at the original.
+func (pc *PostController) setImageURLs(post *models.Post) {
+	for i := range post.Images {
+		pc.setImageURL(&post.Images[i])
+	}
+}
+
+func (pc *PostController) setImageURL(image *models.PostImage) {
+	image.URL = pc.Uploads.URL(image.Filename)
+	image.VariantURLs = map[string]string{"original": image.URL}
+	for _, spec := range upload.PostVariants {
+		image.VariantURLs[spec.Name] = image.URL
+	}
+	for _, variant := range image.Variants {
+		image.VariantURLs[variant.Name] = pc.Uploads.URL(variant.Filename)
+	}
+}
+
+// removeImageFiles deletes the stored original and variants of image.
+func (pc *PostController) removeImageFiles(image *models.PostImage) {
+	pc.Uploads.Delete(image.Filename)
+	for _, variant := range image.Variants {
+		pc.Uploads.Delete(variant.Filename)
+	}
+}
+
+func orderedImages(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+func orderedHistory(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at")
+}
+
+// canAccessPost reports whether user may read or change post: owners always
+// can, and so can staff.
+func canAccessPost(user *models.User, post *models.Post) bool {
+	return user.IsStaff() || post.UserId == user.ID
+}
+
+// postOwner resolves who a post should belong to. Anyone may leave the owner
+// as fallback, but only staff may hand a post to a different user.
+func postOwner(ctx *gin.Context, currentUser *models.User, requested string, fallback uuid.UUID) (uuid.UUID, bool) {
+	if requested == "" {
+		return fallback, true
+	}
+
+	parsedUser, err := uuid.Parse(requested)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+
+	if parsedUser != fallback && !currentUser.IsStaff() {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You cannot assign posts to other users"})
+		return uuid.Nil, false
+	}
+
+	return parsedUser, true
+}
+
+// respondUploadError maps errors from the upload service to a response.
+func respondUploadError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, upload.ErrUnsupportedType):
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{"status": "fail", "message": err.Error()})
+	case errors.Is(err, upload.ErrFileTooLarge), errors.Is(err, upload.ErrRequestTooLarge):
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"status": "fail", "message": err.Error()})
+	case errors.Is(err, upload.ErrTooManyFiles), errors.Is(err, upload.ErrInvalidImage):
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+	case errors.Is(err, upload.ErrInvalidForm):
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Failed to parse form data"})
+	default:
+		log.Println("Failed to save upload:", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to save image file"})
+	}
+}
@@ -1,95 +1,105 @@
 package controllers
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/mliem2k/ottb-go/geo"
 	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/upload"
+	"github.com/mliem2k/ottb-go/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxNearbyRadiusKm bounds how far the nearby search looks, and
+	// maxNearbyResults how many stations it returns.
+	maxNearbyRadiusKm = 500
+	maxNearbyResults  = 100
+	// maxImportSize bounds the GeoJSON accepted by ImportStations.
+	maxImportSize = 10 << 20
 )
 
 type StationController struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	Uploads *upload.Service
 }
 
-func NewStationController(DB *gorm.DB) StationController {
-	return StationController{DB}
+func NewStationController(DB *gorm.DB, Uploads *upload.Service) StationController {
+	return StationController{DB, Uploads}
 }
 
 func (pc *StationController) CreateStation(ctx *gin.Context) {
-	// currentUser := ctx.MustGet("currentUser").(models.User)
+	currentUser := ctx.MustGet("currentUser").(models.User)
 
 	// Parse form data
-	err := ctx.Request.ParseMultipartForm(10 << 20) // 10 MB max
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Failed to parse form data"})
+	if err := pc.Uploads.ParseMultipartForm(ctx.Writer, ctx.Request); err != nil {
+		respondUploadError(ctx, err)
 		return
 	}
 
 	// Extract other fields from the form
 	name := ctx.Request.FormValue("name")
-	latlong := ctx.Request.FormValue("latlong")
-	user := ctx.Request.FormValue("user")
-	parsedUser, err := uuid.Parse(user)
-
+	latitude, longitude, err := formCoordinates(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Invalid user ID"})
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	timezone := ctx.Request.FormValue("timezone")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if !models.ValidTimezone(timezone) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Unknown timezone"})
 		return
 	}
 
-	// Handle file uploads
-	files := ctx.Request.MultipartForm.File["image"]
-	var imageNames []string
-	for _, fileHeader := range files {
-		file, err := fileHeader.Open()
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"status": "error", "message": "Failed to get image file"})
-			return
-		}
-		defer file.Close()
-
-		// Save uploaded file to server with timestamp
-		currentTime := time.Now().UnixNano()
-		imageExtension := filepath.Ext(fileHeader.Filename)
-		imageName := fmt.Sprintf("%d%s", currentTime, imageExtension)
-		filePath := "uploads/" + imageName
-		outFile, err := os.Create(filePath)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to save image file"})
-			return
-		}
-		defer outFile.Close()
-		_, err = io.Copy(outFile, file)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "Failed to save image file"})
-			return
-		}
-		imageNames = append(imageNames, imageName)
+	// Handle file uploads; they become the station's gallery, the first one
+	// its cover.
+	stored, err := pc.Uploads.SaveAll(ctx.Request.Context(), "stations/", ctx.Request.MultipartForm.File["image"])
+	if err != nil {
+		respondUploadError(ctx, err)
+		return
 	}
 
 	// Create new station object
 	now := time.Now()
 	newStation := models.Station{
 		Name:      name,
-		UserId:    parsedUser,
-		LatLong:   latlong,
+		UserId:    currentUser.ID,
+		Latitude:  &latitude,
+		Longitude: &longitude,
+		Timezone:  timezone,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
 	// Save station to database
-	result := pc.DB.Create(&newStation)
-	if result.Error != nil {
-		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": result.Error.Error()})
+	err = pc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newStation).Error; err != nil {
+			return err
+		}
+		_, err := recordPhotos(tx, &newStation, stored)
+		return err
+	})
+	if err != nil {
+		pc.Uploads.DeleteAll(stored)
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
 		return
 	}
+	pc.presentStation(&newStation)
 
 	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": newStation})
 }
@@ -103,21 +113,83 @@ func (pc *StationController) UpdateStation(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadGateway, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
+	if (payload.Latitude == nil) != (payload.Longitude == nil) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Latitude and longitude must be changed together"})
+		return
+	}
+	if payload.Latitude != nil {
+		if err := utils.ValidateCoordinates(*payload.Latitude, *payload.Longitude); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+			return
+		}
+	}
+	if payload.Timezone != nil {
+		if !models.ValidTimezone(*payload.Timezone) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Unknown timezone"})
+			return
+		}
+	}
+	if payload.Hours != nil {
+		for _, hours := range *payload.Hours {
+			if !models.ValidClockTime(hours.Opens) || !models.ValidClockTime(hours.Closes) {
+				ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Opening hours must be given as HH:MM"})
+				return
+			}
+		}
+	}
+
 	var updatedStation models.Station
 	result := pc.DB.First(&updatedStation, "id = ?", stationId)
 	if result.Error != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
 		return
 	}
+	if !canModifyStation(&currentUser, &updatedStation) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to modify this station"})
+		return
+	}
 	now := time.Now()
 	stationToUpdate := models.Station{
 		Name:      payload.Name,
-		UserId:    currentUser.ID,
+		Latitude:  payload.Latitude,
+		Longitude: payload.Longitude,
 		CreatedAt: updatedStation.CreatedAt,
 		UpdatedAt: now,
 	}
+	if payload.Timezone != nil {
+		stationToUpdate.Timezone = *payload.Timezone
+	}
+
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&updatedStation).Updates(stationToUpdate).Error; err != nil {
+			return err
+		}
+		if payload.Hours == nil {
+			return nil
+		}
+		if err := tx.Where("station_id = ?", updatedStation.ID).Delete(&models.StationHours{}).Error; err != nil {
+			return err
+		}
+		for _, input := range *payload.Hours {
+			hours := models.StationHours{
+				StationId: updatedStation.ID,
+				Weekday:   input.Weekday,
+				Opens:     input.Opens,
+				Closes:    input.Closes,
+			}
+			if err := tx.Create(&hours).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
 
-	pc.DB.Model(&updatedStation).Updates(stationToUpdate)
+	preloadStation(pc.DB).First(&updatedStation, "id = ?", updatedStation.ID)
+	pc.presentStation(&updatedStation)
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": updatedStation})
 }
@@ -126,11 +198,12 @@ func (pc *StationController) FindStationById(ctx *gin.Context) {
 	stationId := ctx.Param("stationId")
 
 	var station models.Station
-	result := pc.DB.First(&station, "id = ?", stationId)
+	result := preloadStation(pc.DB).First(&station, "id = ?", stationId)
 	if result.Error != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
 		return
 	}
+	pc.presentStation(&station)
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": station})
 }
@@ -143,25 +216,791 @@ func (pc *StationController) FindStations(ctx *gin.Context) {
 	intLimit, _ := strconv.Atoi(limit)
 	offset := (intPage - 1) * intLimit
 
+	query, err := filterStations(ctx, pc.DB.Model(&models.Station{}))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
 	var stations []models.Station
-	results := pc.DB.Limit(intLimit).Offset(offset).Find(&stations)
+	results := preloadStation(query).Limit(intLimit).Offset(offset).Find(&stations)
 	if results.Error != nil {
 		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error})
 		return
 	}
+	for i := range stations {
+		pc.presentStation(&stations[i])
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(stations), "data": stations})
+}
+
+// ExportStationsGeoJSON returns the stations matching the FindStations
+// filters as a GeoJSON FeatureCollection.
+func (pc *StationController) ExportStationsGeoJSON(ctx *gin.Context) {
+	collection, ok := pc.exportStations(ctx)
+	if !ok {
+		return
+	}
+
+	body, err := json.Marshal(collection)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="stations.geojson"`)
+	ctx.Data(http.StatusOK, "application/geo+json", body)
+}
+
+// ExportStationsKML returns the stations matching the FindStations filters as
+// a KML document.
+func (pc *StationController) ExportStationsKML(ctx *gin.Context) {
+	collection, ok := pc.exportStations(ctx)
+	if !ok {
+		return
+	}
+
+	var body bytes.Buffer
+	if err := geo.WriteKML(&body, "OTTB stations", collection); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="stations.kml"`)
+	ctx.Data(http.StatusOK, "application/vnd.google-earth.kml+xml", body.Bytes())
+}
+
+// exportStations loads the stations to export, with their post counts, as
+// GeoJSON features. It responds itself when that fails.
+func (pc *StationController) exportStations(ctx *gin.Context) (geo.FeatureCollection, bool) {
+	query, err := filterStations(ctx, pc.DB.Model(&models.Station{}))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return geo.FeatureCollection{}, false
+	}
+
+	var stations []models.Station
+	results := query.
+		Select("stations.*, (SELECT COUNT(*) FROM posts WHERE posts.station_id::uuid = stations.id) AS post_count").
+		Order("stations.name").
+		Find(&stations)
+	if results.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error.Error()})
+		return geo.FeatureCollection{}, false
+	}
+
+	features := make([]geo.Feature, 0, len(stations))
+	for _, station := range stations {
+		properties := map[string]interface{}{
+			"name":       station.Name,
+			"user_id":    station.UserId.String(),
+			"created_at": station.CreatedAt.Format(time.RFC3339),
+			"updated_at": station.UpdatedAt.Format(time.RFC3339),
+		}
+		if station.ExternalRef != nil {
+			properties["external_ref"] = *station.ExternalRef
+		}
+		if station.PostCount != nil {
+			properties["post_count"] = *station.PostCount
+		}
+		features = append(features, geo.Feature{
+			Type:       "Feature",
+			ID:         station.ID.String(),
+			Geometry:   geo.NewPoint(station.Latitude, station.Longitude),
+			Properties: properties,
+		})
+	}
+	return geo.NewFeatureCollection(features), true
+}
+
+// ImportStations creates or updates stations from a GeoJSON FeatureCollection.
+// Features are matched to stations by their "external_ref" property or, when
+// they have none, by their id, which also matches the station with that id as
+// in our own exports. Importing the same file again therefore changes nothing.
+// Either every feature is imported or, if any is invalid, none is.
+func (pc *StationController) ImportStations(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxImportSize+1))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if len(body) > maxImportSize {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"status": "fail", "message": "The GeoJSON file is too large"})
+		return
+	}
+	collection, err := geo.ParseFeatureCollection(body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	type importedStation struct {
+		ref string
+		// id is set when the ref is a feature id naming a station, as in
+		// our own exports of stations without an external_ref
+		id        *uuid.UUID
+		name      string
+		latitude  float64
+		longitude float64
+	}
+	var (
+		imports  []importedStation
+		problems []string
+		seen     = make(map[string]bool)
+	)
+	for i, feature := range collection.Features {
+		ref := feature.StringProperty("external_ref")
+		var stationId *uuid.UUID
+		if ref == "" {
+			if id, ok := feature.ID.(string); ok {
+				ref = id
+				if id, err := uuid.Parse(id); err == nil {
+					stationId = &id
+				}
+			} else if id, ok := feature.ID.(float64); ok {
+				ref = strconv.FormatFloat(id, 'f', -1, 64)
+			}
+		}
+		name := feature.StringProperty("name")
+
+		switch {
+		case ref == "":
+			problems = append(problems, fmt.Sprintf("feature %d: missing external_ref", i))
+		case seen[ref]:
+			problems = append(problems, fmt.Sprintf("feature %d: duplicate external_ref %q", i, ref))
+		case name == "":
+			problems = append(problems, fmt.Sprintf("feature %d: missing name", i))
+		case feature.Geometry == nil:
+			problems = append(problems, fmt.Sprintf("feature %d: missing geometry", i))
+		default:
+			lat, lng := feature.Geometry.LatLng()
+			if err := utils.ValidateCoordinates(lat, lng); err != nil {
+				problems = append(problems, fmt.Sprintf("feature %d: %v", i, err))
+				continue
+			}
+			imports = append(imports, importedStation{ref, stationId, name, lat, lng})
+		}
+		seen[ref] = true
+	}
+	if len(problems) > 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "The GeoJSON contains invalid stations", "errors": problems})
+		return
+	}
+
+	created, updated := 0, 0
+	err = pc.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, imported := range imports {
+			ref, lat, lng := imported.ref, imported.latitude, imported.longitude
+
+			var station models.Station
+			query := tx.Where("external_ref = ?", ref)
+			if imported.id != nil {
+				query = query.Or("id = ?", *imported.id)
+			}
+			result := query.Limit(1).Find(&station)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected == 0 {
+				if err := tx.Create(&models.Station{
+					Name:        imported.name,
+					Latitude:    &lat,
+					Longitude:   &lng,
+					UserId:      currentUser.ID,
+					ExternalRef: &ref,
+					CreatedAt:   now,
+					UpdatedAt:   now,
+				}).Error; err != nil {
+					return err
+				}
+				created++
+				continue
+			}
+
+			unchanged := station.Name == imported.name &&
+				station.Latitude != nil && *station.Latitude == lat &&
+				station.Longitude != nil && *station.Longitude == lng
+			if unchanged {
+				continue
+			}
+			if err := tx.Model(&station).Updates(map[string]interface{}{
+				"name":       imported.name,
+				"latitude":   lat,
+				"longitude":  lng,
+				"updated_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{
+		"created":   created,
+		"updated":   updated,
+		"unchanged": len(imports) - created - updated,
+	}})
+}
+
+// FindNearbyStations lists the stations within radius kilometres (default 10)
+// of lat/lng, closest first, with their great-circle distance.
+func (pc *StationController) FindNearbyStations(ctx *gin.Context) {
+	lat, latErr := strconv.ParseFloat(ctx.Query("lat"), 64)
+	lng, lngErr := strconv.ParseFloat(ctx.Query("lng"), 64)
+	if latErr != nil || lngErr != nil || utils.ValidateCoordinates(lat, lng) != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "lat and lng must be valid coordinates"})
+		return
+	}
+	radius, err := strconv.ParseFloat(ctx.DefaultQuery("radius", "10"), 64)
+	if err != nil || math.IsNaN(radius) || radius <= 0 || radius > maxNearbyRadiusKm {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "radius must be a distance in kilometres up to " + strconv.Itoa(maxNearbyRadiusKm)})
+		return
+	}
+	intLimit, err := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if err != nil {
+		intLimit = 10
+	}
+	// Without a positive limit gorm would leave out LIMIT altogether
+	intLimit = max(1, min(intLimit, maxNearbyResults))
+
+	// Haversine distance; stations outside the latitude band of the radius are
+	// skipped before computing it.
+	latDelta := radius / 111.0
+	distances := pc.DB.Model(&models.Station{}).
+		Select(`stations.*, 2 * CAST(? AS double precision) * ASIN(LEAST(1, SQRT(
+			POWER(SIN(RADIANS(latitude - ?) / 2), 2) +
+			COS(RADIANS(?)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - ?) / 2), 2)
+		))) AS distance_km`, utils.EarthRadiusKm, lat, lat, lng).
+		Where("latitude BETWEEN ? AND ? AND longitude IS NOT NULL", lat-latDelta, lat+latDelta)
+	distances, err = filterStations(ctx, distances)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	var stations []models.Station
+	results := preloadStation(pc.DB.Table("(?) AS stations", distances)).
+		Where("distance_km <= ?", radius).
+		Order("distance_km").
+		Limit(intLimit).
+		Find(&stations)
+	if results.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error.Error()})
+		return
+	}
+	for i := range stations {
+		pc.presentStation(&stations[i])
+	}
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(stations), "data": stations})
 }
 
 func (pc *StationController) DeleteStation(ctx *gin.Context) {
 	stationId := ctx.Param("stationId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
 
-	result := pc.DB.Delete(&models.Station{}, "id = ?", stationId)
+	var station models.Station
+	if pc.DB.Preload("Photos").First(&station, "id = ?", stationId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
+		return
+	}
+	if !canModifyStation(&currentUser, &station) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to delete this station"})
+		return
+	}
 
-	if result.Error != nil {
+	var result *gorm.DB
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		for _, dependent := range []interface{}{
+			&models.StationPhoto{}, &models.StationHours{}, &models.StationClosure{},
+			&models.StationDevice{}, &models.StationHeartbeat{},
+		} {
+			if err := tx.Where("station_id = ?", station.ID).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		result = tx.Delete(&models.Station{}, "id = ?", stationId)
+		return result.Error
+	})
+
+	if err != nil || result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
+		return
+	}
+
+	for _, photo := range station.Photos {
+		pc.Uploads.Delete(photo.Filename)
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+// AddStationPhotos appends the uploaded "image" files to a station's gallery.
+func (pc *StationController) AddStationPhotos(ctx *gin.Context) {
+	station, ok := pc.modifiableStation(ctx)
+	if !ok {
+		return
+	}
+
+	if err := pc.Uploads.ParseMultipartForm(ctx.Writer, ctx.Request); err != nil {
+		respondUploadError(ctx, err)
+		return
+	}
+	files := ctx.Request.MultipartForm.File["image"]
+	if len(files) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "No image files were uploaded"})
+		return
+	}
+
+	stored, err := pc.Uploads.SaveAll(ctx.Request.Context(), "stations/", files)
+	if err != nil {
+		respondUploadError(ctx, err)
+		return
+	}
+
+	var photos []models.StationPhoto
+	err = pc.DB.Transaction(func(tx *gorm.DB) error {
+		photos, err = recordPhotos(tx, &station, stored)
+		return err
+	})
+	if err != nil {
+		pc.Uploads.DeleteAll(stored)
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	for i := range photos {
+		photos[i].URL = pc.Uploads.URL(photos[i].Filename)
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": photos})
+}
+
+// ReorderStationPhotos sets the order of a station's gallery. The payload must
+// list every photo of the station exactly once.
+func (pc *StationController) ReorderStationPhotos(ctx *gin.Context) {
+	var payload *models.ReorderStationPhotosInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	station, ok := pc.modifiableStation(ctx)
+	if !ok {
+		return
+	}
+
+	var photos []models.StationPhoto
+	pc.DB.Where("station_id = ?", station.ID).Find(&photos)
+	existing := make(map[uuid.UUID]bool, len(photos))
+	for _, photo := range photos {
+		existing[photo.ID] = true
+	}
+	if len(payload.PhotoIds) != len(existing) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "photo_ids must list every photo of the station exactly once"})
+		return
+	}
+	for _, id := range payload.PhotoIds {
+		if !existing[id] {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "photo_ids must list every photo of the station exactly once"})
+			return
+		}
+		delete(existing, id)
+	}
+
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		for position, id := range payload.PhotoIds {
+			if err := tx.Model(&models.StationPhoto{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	pc.DB.Preload("Photos", orderedPhotos).First(&station, "id = ?", station.ID)
+	pc.setPhotoURLs(&station)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": station.Photos})
+}
+
+// SetStationCover makes one of a station's photos its cover.
+func (pc *StationController) SetStationCover(ctx *gin.Context) {
+	photoId := ctx.Param("photoId")
+
+	station, ok := pc.modifiableStation(ctx)
+	if !ok {
+		return
+	}
+
+	var photo models.StationPhoto
+	if pc.DB.First(&photo, "id = ? AND station_id = ?", photoId, station.ID).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No photo with that ID exists on this station"})
+		return
+	}
+
+	if err := pc.DB.Model(&station).Updates(map[string]interface{}{"cover_photo_id": photo.ID, "updated_at": time.Now()}).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	preloadStation(pc.DB).First(&station, "id = ?", station.ID)
+	pc.presentStation(&station)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": station})
+}
+
+// DeleteStationPhoto removes a photo from a station's gallery. When it was the
+// cover, the next photo in the gallery becomes the cover.
+func (pc *StationController) DeleteStationPhoto(ctx *gin.Context) {
+	photoId := ctx.Param("photoId")
+
+	station, ok := pc.modifiableStation(ctx)
+	if !ok {
+		return
+	}
+
+	var photo models.StationPhoto
+	if pc.DB.First(&photo, "id = ? AND station_id = ?", photoId, station.ID).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No photo with that ID exists on this station"})
+		return
+	}
+
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&photo).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.StationPhoto{}).
+			Where("station_id = ? AND position > ?", station.ID, photo.Position).
+			Update("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+		if station.CoverPhotoId == nil || *station.CoverPhotoId != photo.ID {
+			return nil
+		}
+
+		var next []models.StationPhoto
+		if err := orderedPhotos(tx).Where("station_id = ?", station.ID).Limit(1).Find(&next).Error; err != nil {
+			return err
+		}
+		var cover interface{}
+		if len(next) > 0 {
+			cover = next[0].ID
+		}
+		return tx.Model(&station).Update("cover_photo_id", cover).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	pc.Uploads.Delete(photo.Filename)
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+// FindStationClosures lists all closures of a station, past ones included.
+func (pc *StationController) FindStationClosures(ctx *gin.Context) {
+	stationId := ctx.Param("stationId")
+
+	var station models.Station
+	if pc.DB.First(&station, "id = ?", stationId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
+		return
+	}
+
+	var closures []models.StationClosure
+	results := pc.DB.Where("station_id = ?", station.ID).Order("starts_at DESC").Find(&closures)
+	if results.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": results.Error.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "results": len(closures), "data": closures})
+}
+
+// CreateStationClosure schedules a period in which a station is closed.
+func (pc *StationController) CreateStationClosure(ctx *gin.Context) {
+	stationId := ctx.Param("stationId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.CreateStationClosureInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if !payload.EndsAt.After(payload.StartsAt) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "A closure must end after it starts"})
+		return
+	}
+	if !payload.EndsAt.After(time.Now()) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "A closure must end in the future"})
+		return
+	}
+
+	var station models.Station
+	if pc.DB.First(&station, "id = ?", stationId).Error != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
 		return
 	}
 
+	closure := models.StationClosure{
+		StationId: station.ID,
+		StartsAt:  payload.StartsAt,
+		EndsAt:    payload.EndsAt,
+		Reason:    payload.Reason,
+		CreatedBy: currentUser.ID,
+		CreatedAt: time.Now(),
+	}
+	if err := pc.DB.Create(&closure).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "data": closure})
+}
+
+// DeleteStationClosure cancels a closure, reopening the station early if it
+// is under way.
+func (pc *StationController) DeleteStationClosure(ctx *gin.Context) {
+	stationId := ctx.Param("stationId")
+	closureId := ctx.Param("closureId")
+
+	result := pc.DB.Delete(&models.StationClosure{}, "id = ? AND station_id = ?", closureId, stationId)
+	if result.Error != nil || result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No closure with that ID exists"})
+		return
+	}
+
 	ctx.JSON(http.StatusNoContent, nil)
 }
+
+// modifiableStation loads the station of the request and checks that the
+// current user may change it, responding itself when not.
+func (pc *StationController) modifiableStation(ctx *gin.Context) (models.Station, bool) {
+	stationId := ctx.Param("stationId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var station models.Station
+	if pc.DB.First(&station, "id = ?", stationId).Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No station with that title exists"})
+		return station, false
+	}
+	if !canModifyStation(&currentUser, &station) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You are not allowed to modify this station"})
+		return station, false
+	}
+	return station, true
+}
+
+// recordPhotos adds stored files after the station's existing photos, within
+// tx, and makes the first one the cover if the station has none yet.
+func recordPhotos(tx *gorm.DB, station *models.Station, stored []upload.File) ([]models.StationPhoto, error) {
+	if len(stored) == 0 {
+		return nil, nil
+	}
+
+	// Lock the station so concurrent uploads take their positions, and the
+	// cover, one after the other
+	var locked models.Station
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "cover_photo_id").
+		First(&locked, "id = ?", station.ID).Error; err != nil {
+		return nil, err
+	}
+	station.CoverPhotoId = locked.CoverPhotoId
+
+	var next int
+	if err := tx.Model(&models.StationPhoto{}).
+		Where("station_id = ?", station.ID).
+		Select("COALESCE(MAX(position) + 1, 0)").
+		Scan(&next).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	photos := make([]models.StationPhoto, 0, len(stored))
+	for _, file := range stored {
+		photo := models.StationPhoto{
+			StationId: station.ID,
+			Position:  next,
+			Filename:  file.Key,
+			Size:      file.Size,
+			MimeType:  file.MimeType,
+			Width:     file.Width,
+			Height:    file.Height,
+			Checksum:  file.Checksum,
+			CreatedAt: now,
+		}
+		if err := tx.Create(&photo).Error; err != nil {
+			return nil, err
+		}
+		photos = append(photos, photo)
+		next++
+	}
+
+	if station.CoverPhotoId == nil {
+		station.CoverPhotoId = &photos[0].ID
+		if err := tx.Model(station).Update("cover_photo_id", photos[0].ID).Error; err != nil {
+			return nil, err
+		}
+	}
+	station.Photos = append(station.Photos, photos...)
+	return photos, nil
+}
+
+// presentStation fills in the computed fields of a station loaded with
+// preloadStation.
+func (pc *StationController) presentStation(station *models.Station) {
+	pc.setPhotoURLs(station)
+	openNow := station.OpenAt(time.Now())
+	station.OpenNow = &openNow
+}
+
+// preloadStation loads what presentStation needs: the photos, the opening
+// hours and the closures that have not ended yet.
+func preloadStation(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Photos", orderedPhotos).
+		Preload("Hours", func(db *gorm.DB) *gorm.DB {
+			return db.Order("weekday, opens")
+		}).
+		Preload("Closures", func(db *gorm.DB) *gorm.DB {
+			return db.Where("ends_at > ?", time.Now()).Order("starts_at")
+		})
+}
+
+// setPhotoURLs fills in the public URLs of a station's photos and cover.
+func (pc *StationController) setPhotoURLs(station *models.Station) {
+	for i := range station.Photos {
+		photo := &station.Photos[i]
+		photo.URL = pc.Uploads.URL(photo.Filename)
+		if station.CoverPhotoId != nil && *station.CoverPhotoId == photo.ID {
+			station.CoverURL = photo.URL
+		}
+	}
+}
+
+func orderedPhotos(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// openNowCondition matches the stations that are open at @now; it mirrors
+// models.Station.OpenAt.
+const openNowCondition = `NOT EXISTS (
+		SELECT 1 FROM station_closures c
+		WHERE c.station_id = stations.id AND c.starts_at <= @now AND c.ends_at > @now
+	) AND (
+		NOT EXISTS (SELECT 1 FROM station_hours h WHERE h.station_id = stations.id)
+		OR EXISTS (
+			SELECT 1 FROM station_hours h,
+				LATERAL (SELECT CAST(@now AS timestamptz) AT TIME ZONE stations.timezone AS at) l
+			WHERE h.station_id = stations.id AND (
+				(h.weekday = EXTRACT(DOW FROM l.at)
+					AND CAST(h.opens AS time) <= CAST(l.at AS time)
+					AND (CAST(h.closes AS time) <= CAST(h.opens AS time) OR CAST(l.at AS time) < CAST(h.closes AS time)))
+				OR (h.weekday = (CAST(EXTRACT(DOW FROM l.at) AS integer) + 6) % 7
+					AND CAST(h.closes AS time) <= CAST(h.opens AS time)
+					AND CAST(l.at AS time) < CAST(h.closes AS time))
+			)
+		)
+	)`
+
+// filterStations applies the filters shared by the station listings:
+// q (name contains), user_id, bbox=minLng,minLat,maxLng,maxLat,
+// open_now=true|false and offline=true|false.
+func filterStations(ctx *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if offline := ctx.Query("offline"); offline != "" {
+		flagged, err := strconv.ParseBool(offline)
+		if err != nil {
+			return nil, errors.New("offline must be true or false")
+		}
+		if flagged {
+			query = query.Where("offline_since IS NOT NULL")
+		} else {
+			query = query.Where("offline_since IS NULL")
+		}
+	}
+	if openNow := ctx.Query("open_now"); openNow != "" {
+		open, err := strconv.ParseBool(openNow)
+		if err != nil {
+			return nil, errors.New("open_now must be true or false")
+		}
+		condition := openNowCondition
+		if !open {
+			condition = "NOT (" + openNowCondition + ")"
+		}
+		query = query.Where(condition, sql.Named("now", time.Now()))
+	}
+	if q := ctx.Query("q"); q != "" {
+		query = query.Where("stations.name ILIKE ?", "%"+escapeLike(q)+"%")
+	}
+	if userId := ctx.Query("user_id"); userId != "" {
+		parsed, err := uuid.Parse(userId)
+		if err != nil {
+			return nil, errors.New("invalid user_id")
+		}
+		query = query.Where("stations.user_id = ?", parsed)
+	}
+	if bbox := ctx.Query("bbox"); bbox != "" {
+		var corners [4]float64
+		parts := strings.Split(bbox, ",")
+		if len(parts) != 4 {
+			return nil, errors.New("bbox must be minLng,minLat,maxLng,maxLat")
+		}
+		for i, part := range parts {
+			value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, errors.New("bbox must be minLng,minLat,maxLng,maxLat")
+			}
+			corners[i] = value
+		}
+		query = query.Where("stations.latitude BETWEEN ? AND ? AND stations.longitude BETWEEN ? AND ?",
+			corners[1], corners[3], corners[0], corners[2])
+	}
+	return query, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// formCoordinates reads a station's position from the "latitude" and
+// "longitude" form fields, or from a combined "latlong" field as older clients
+// send it.
+func formCoordinates(ctx *gin.Context) (float64, float64, error) {
+	latitude := ctx.Request.FormValue("latitude")
+	longitude := ctx.Request.FormValue("longitude")
+	if latitude == "" && longitude == "" {
+		return utils.ParseLatLong(ctx.Request.FormValue("latlong"))
+	}
+
+	lat, err := strconv.ParseFloat(latitude, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid latitude")
+	}
+	lng, err := strconv.ParseFloat(longitude, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid longitude")
+	}
+	return lat, lng, utils.ValidateCoordinates(lat, lng)
+}
+
+// canModifyStation reports whether user may change station: admins manage every
+// station, operators only the ones they created.
+func canModifyStation(user *models.User, station *models.Station) bool {
+	return user.Role == models.RoleAdmin || station.UserId == user.ID
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mliem2k/ottb-go/models"
@@ -28,7 +29,131 @@ func (uc *UserController) GetMe(ctx *gin.Context) {
 		Provider:  currentUser.Provider,
 		CreatedAt: currentUser.CreatedAt,
 		UpdatedAt: currentUser.UpdatedAt,
+
+		NotifyOnDeveloped: currentUser.NotifyOnDeveloped,
+		MfaEnabled:        currentUser.MfaEnabled(),
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"user": userResponse}})
+}
+
+// UpdateUserRole lets an admin promote or demote another user.
+func (uc *UserController) UpdateUserRole(ctx *gin.Context) {
+	userId := ctx.Param("userId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.UpdateRoleInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	var user models.User
+	result := uc.DB.First(&user, "id = ?", userId)
+	if result.Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No user with that ID exists"})
+		return
+	}
+
+	if user.ID == currentUser.ID && payload.Role != models.RoleAdmin {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "You cannot remove your own admin role"})
+		return
+	}
+
+	if err := uc.DB.Model(&user).Updates(models.User{Role: payload.Role, UpdatedAt: time.Now()}).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	userResponse := &models.UserResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Username:  user.Username,
+		Email:     user.Email,
+		Photo:     user.Photo,
+		Role:      user.Role,
+		Provider:  user.Provider,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+
+		NotifyOnDeveloped: user.NotifyOnDeveloped,
+		MfaEnabled:        user.MfaEnabled(),
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"user": userResponse}})
+}
+
+// UpdatePreferences changes the current user's notification settings.
+func (uc *UserController) UpdatePreferences(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.UpdatePreferencesInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	// A map so that turning notifications off is not skipped as a zero value.
+	currentUser.NotifyOnDeveloped = *payload.NotifyOnDeveloped
+	currentUser.UpdatedAt = time.Now()
+	result := uc.DB.Model(&currentUser).Updates(map[string]interface{}{
+		"notify_on_developed": currentUser.NotifyOnDeveloped,
+		"updated_at":          currentUser.UpdatedAt,
+	})
+	if result.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+
+	userResponse := &models.UserResponse{
+		ID:        currentUser.ID,
+		Name:      currentUser.Name,
+		Username:  currentUser.Username,
+		Email:     currentUser.Email,
+		Photo:     currentUser.Photo,
+		Role:      currentUser.Role,
+		Provider:  currentUser.Provider,
+		CreatedAt: currentUser.CreatedAt,
+		UpdatedAt: currentUser.UpdatedAt,
+
+		NotifyOnDeveloped: currentUser.NotifyOnDeveloped,
+		MfaEnabled:        currentUser.MfaEnabled(),
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "data": gin.H{"user": userResponse}})
 }
+
+// UnlockUser lets an admin lift a lockout after failed sign-ins early.
+func (uc *UserController) UnlockUser(ctx *gin.Context) {
+	userId := ctx.Param("userId")
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var user models.User
+	result := uc.DB.First(&user, "id = ?", userId)
+	if result.Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "No user with that ID exists"})
+		return
+	}
+	if !user.Locked(time.Now()) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "This account is not locked"})
+		return
+	}
+
+	now := time.Now()
+	err := uc.DB.Transaction(func(tx *gorm.DB) error {
+		// The mailed unlock link has nothing left to do
+		if err := tx.Model(&models.AccountUnlock{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user).UpdateColumn("locked_until", nil).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	recordAudit(uc.DB, ctx, models.AuditAccountUnlocked, "", &user, &currentUser, "unlocked by an admin")
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,83 @@
+// Package geo encodes and decodes the map formats stations are exchanged in.
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection (RFC 7946).
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature. Only Point geometries are supported.
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *Point                 `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Point is a GeoJSON Point. Coordinates are [longitude, latitude].
+type Point struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// NewPoint returns a Point at lat/lng, or nil when either is missing.
+func NewPoint(lat, lng *float64) *Point {
+	if lat == nil || lng == nil {
+		return nil
+	}
+	return &Point{Type: "Point", Coordinates: []float64{*lng, *lat}}
+}
+
+// ParseFeatureCollection decodes data, checking that it is a FeatureCollection
+// of features with Point geometries.
+func ParseFeatureCollection(data []byte) (FeatureCollection, error) {
+	var collection FeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return FeatureCollection{}, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return FeatureCollection{}, errors.New("expected a GeoJSON FeatureCollection")
+	}
+
+	for i, feature := range collection.Features {
+		if feature.Type != "Feature" {
+			return FeatureCollection{}, fmt.Errorf("feature %d: expected type Feature", i)
+		}
+		if feature.Geometry == nil {
+			continue
+		}
+		if feature.Geometry.Type != "Point" {
+			return FeatureCollection{}, fmt.Errorf("feature %d: only Point geometries are supported", i)
+		}
+		if len(feature.Geometry.Coordinates) < 2 {
+			return FeatureCollection{}, fmt.Errorf("feature %d: a Point needs a longitude and a latitude", i)
+		}
+	}
+	return collection, nil
+}
+
+// LatLng returns the position of a Point.
+func (p *Point) LatLng() (float64, float64) {
+	return p.Coordinates[1], p.Coordinates[0]
+}
+
+// StringProperty returns the property key of f as a string, or "" when it is
+// missing or not a string.
+func (f *Feature) StringProperty(key string) string {
+	value, _ := f.Properties[key].(string)
+	return value
+}
@@ -0,0 +1,93 @@
+package geo
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+)
+
+type kml struct {
+	XMLName  xml.Name    `xml:"kml"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	ID           string    `xml:"id,attr,omitempty"`
+	Name         string    `xml:"name"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data"`
+	Point        *kmlPoint `xml:"Point,omitempty"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// WriteKML writes collection as a KML document called name. The "name"
+// property of each feature becomes the placemark name; the other properties
+// become its extended data.
+func WriteKML(w io.Writer, name string, collection FeatureCollection) error {
+	doc := kml{Xmlns: "http://www.opengis.net/kml/2.2", Document: kmlDocument{Name: name}}
+
+	for _, feature := range collection.Features {
+		placemark := kmlPlacemark{Name: feature.StringProperty("name")}
+		if id, ok := feature.ID.(string); ok {
+			placemark.ID = id
+		}
+
+		keys := make([]string, 0, len(feature.Properties))
+		for key := range feature.Properties {
+			if key != "name" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			placemark.ExtendedData = append(placemark.ExtendedData, kmlData{Name: key, Value: kmlValue(feature.Properties[key])})
+		}
+
+		if feature.Geometry != nil {
+			lat, lng := feature.Geometry.LatLng()
+			placemark.Point = &kmlPoint{Coordinates: strconv.FormatFloat(lng, 'f', -1, 64) + "," + strconv.FormatFloat(lat, 'f', -1, 64)}
+		}
+		doc.Document.Placemarks = append(doc.Document.Placemarks, placemark)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+func kmlValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case interface{ String() string }:
+		return v.String()
+	}
+	return ""
+}
@@ -11,6 +11,7 @@ require (
 	github.com/google/uuid v1.5.0
 	github.com/spf13/viper v1.12.0
 	golang.org/x/crypto v0.21.0
+	golang.org/x/image v0.15.0
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
 	gorm.io/driver/postgres v1.3.8
 	gorm.io/gorm v1.25.7-0.20240204074919-46816ad31dde
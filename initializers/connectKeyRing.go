@@ -0,0 +1,45 @@
+package initializers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mliem2k/ottb-go/keyring"
+)
+
+// AccessTokens signs and verifies access tokens.
+var AccessTokens *keyring.Ring
+
+func ConnectKeyRing(config *Config) {
+	var keys []keyring.Key
+	var err error
+	if config.AccessTokenKeys != "" {
+		keys, err = keyring.ParseKeys(config.AccessTokenKeys)
+	} else {
+		var key keyring.Key
+		key, err = keyring.DecodeKey(config.AccessTokenPrivateKey, config.AccessTokenPublicKey)
+		keys = []keyring.Key{key}
+	}
+	if err != nil {
+		log.Fatal("Failed to load access token keys: ", err)
+	}
+
+	// A key must stay accepted for as long as the last token it signed lives
+	overlap := config.AccessTokenKeyOverlap
+	if overlap < config.AccessTokenExpiresIn {
+		overlap = config.AccessTokenExpiresIn
+	}
+
+	AccessTokens, err = keyring.New(keys, overlap)
+	if err != nil {
+		log.Fatal("Failed to load access token keys: ", err)
+	}
+
+	signer, err := AccessTokens.Signer(time.Now())
+	if err != nil {
+		log.Println("⚠️ Access tokens cannot be issued:", err)
+		return
+	}
+	fmt.Println("🚀 Signing access tokens with key", signer.ID)
+}
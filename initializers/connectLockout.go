@@ -0,0 +1,38 @@
+package initializers
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mliem2k/ottb-go/lockout"
+)
+
+// Logins throttles failed sign-ins.
+var Logins *lockout.Limiter
+
+func ConnectLockout(config *Config) {
+	var store lockout.Store
+	switch config.LoginLimiterDriver {
+	case "database", "":
+		store = lockout.NewDatabaseStore(DB)
+	case "memory":
+		store = lockout.NewMemoryStore()
+	default:
+		log.Fatal("Failed to set up sign-in throttling: ", fmt.Errorf("unknown limiter driver %q", config.LoginLimiterDriver))
+	}
+
+	Logins = &lockout.Limiter{
+		Store:  store,
+		Window: config.LoginFailureWindow,
+		Account: lockout.Policy{
+			FreeAttempts: config.LoginFreeAttempts,
+			BaseDelay:    config.LoginDelayBase,
+			MaxDelay:     config.LoginDelayMax,
+		},
+		IP: lockout.Policy{
+			FreeAttempts: config.LoginIPFreeAttempts,
+			BaseDelay:    config.LoginDelayBase,
+			MaxDelay:     config.LoginDelayMax,
+		},
+	}
+}
@@ -0,0 +1,30 @@
+package initializers
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mliem2k/ottb-go/mailer"
+)
+
+// Mail delivers outgoing email. The file and memory drivers are a
+// mailer.Mailbox that captures messages instead of sending them.
+var Mail mailer.Transport
+
+func ConnectMailer(config *Config) {
+	var err error
+	switch config.MailDriver {
+	case "smtp", "":
+		Mail = mailer.NewSMTPTransport(config.SmtpFrom, config.SmtpServer, config.SmtpPort, config.SmtpUser, config.SmtpPass)
+	case "file":
+		Mail, err = mailer.NewFileTransport(config.SmtpFrom, config.MailFileDir)
+	case "memory":
+		Mail = mailer.NewMemoryTransport(config.SmtpFrom, 100)
+	default:
+		err = fmt.Errorf("unknown mail driver %q", config.MailDriver)
+	}
+	if err != nil {
+		log.Fatal("Failed to set up mail delivery: ", err)
+	}
+	fmt.Println("🚀 Using", config.MailDriver, "mail delivery")
+}
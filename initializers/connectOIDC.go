@@ -0,0 +1,31 @@
+package initializers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mliem2k/ottb-go/oidc"
+)
+
+// OIDC is the provider users may sign in with; nil unless OIDC_ISSUER is set.
+var OIDC *oidc.Provider
+
+func ConnectOIDC(config *Config) {
+	if config.OidcIssuer == "" {
+		return
+	}
+
+	redirectURL := config.OidcRedirectURL
+	if redirectURL == "" {
+		redirectURL = config.ServerOrigin + "/api/auth/oidc/callback"
+	}
+	OIDC = oidc.NewProvider(oidc.Config{
+		Name:         config.OidcProvider,
+		Issuer:       config.OidcIssuer,
+		ClientID:     config.OidcClientID,
+		ClientSecret: config.OidcClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       strings.Fields(config.OidcScopes),
+	})
+	fmt.Println("🚀 Signing in with", config.OidcProvider, "at", config.OidcIssuer)
+}
@@ -0,0 +1,34 @@
+package initializers
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mliem2k/ottb-go/storage"
+)
+
+var Store storage.Store
+
+func ConnectStorage(config *Config) {
+	var err error
+	switch config.StorageDriver {
+	case "s3":
+		Store, err = storage.NewS3Store(storage.S3Config{
+			Endpoint:  config.S3Endpoint,
+			Region:    config.S3Region,
+			Bucket:    config.S3Bucket,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+			PublicURL: config.S3PublicURL,
+			PathStyle: config.S3PathStyle,
+		})
+	case "local", "":
+		Store, err = storage.NewLocalStore(config.StorageLocalDir, config.ServerOrigin+"/uploads")
+	default:
+		err = fmt.Errorf("unknown storage driver %q", config.StorageDriver)
+	}
+	if err != nil {
+		log.Fatal("Failed to set up file storage: ", err)
+	}
+	fmt.Println("🚀 Using", config.StorageDriver, "file storage")
+}
@@ -13,6 +13,9 @@ type Config struct {
 	DBName         string `mapstructure:"POSTGRES_DB"`
 	DBPort         string `mapstructure:"POSTGRES_PORT"`
 	ServerPort     string `mapstructure:"PORT"`
+	// AppEnv is "development" or "production"; development enables the
+	// /api/dev helpers.
+	AppEnv string `mapstructure:"APP_ENV"`
 
 	SmtpServer string `mapstructure:"SMTP_SERVER"`
 	SmtpPort   int    `mapstructure:"SMTP_PORT"`
@@ -20,8 +23,48 @@ type Config struct {
 	SmtpPass   string `mapstructure:"SMTP_PASS"`
 	SmtpFrom   string `mapstructure:"SMTP_FROM"`
 
+	// How email leaves the server: "smtp", "file" (.eml files in MailFileDir)
+	// or "memory". The last two never reach a real inbox.
+	MailDriver  string `mapstructure:"MAIL_DRIVER"`
+	MailFileDir string `mapstructure:"MAIL_FILE_DIR"`
+
+	// Background delivery of queued email; see the outbox package.
+	OutboxPollInterval   time.Duration `mapstructure:"OUTBOX_POLL_INTERVAL"`
+	OutboxMaxAttempts    int           `mapstructure:"OUTBOX_MAX_ATTEMPTS"`
+	OutboxRetryBaseDelay time.Duration `mapstructure:"OUTBOX_RETRY_BASE_DELAY"`
+	OutboxRetryMaxDelay  time.Duration `mapstructure:"OUTBOX_RETRY_MAX_DELAY"`
+
+	// Station devices report every HeartbeatInterval; a station that misses
+	// HeartbeatMissedLimit heartbeats in a row is flagged offline.
+	HeartbeatInterval    time.Duration `mapstructure:"HEARTBEAT_INTERVAL"`
+	HeartbeatMissedLimit int           `mapstructure:"HEARTBEAT_MISSED_LIMIT"`
+	HeartbeatRetention   time.Duration `mapstructure:"HEARTBEAT_RETENTION"`
+
+	// Where uploads are kept: "local" (StorageLocalDir, served under /uploads)
+	// or "s3" for any S3-compatible bucket.
+	StorageDriver   string `mapstructure:"STORAGE_DRIVER"`
+	StorageLocalDir string `mapstructure:"STORAGE_LOCAL_DIR"`
+	S3Endpoint      string `mapstructure:"S3_ENDPOINT"`
+	S3Region        string `mapstructure:"S3_REGION"`
+	S3Bucket        string `mapstructure:"S3_BUCKET"`
+	S3AccessKey     string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey     string `mapstructure:"S3_SECRET_KEY"`
+	S3PublicURL     string `mapstructure:"S3_PUBLIC_URL"`
+	S3PathStyle     bool   `mapstructure:"S3_PATH_STYLE"`
+
+	// Upload limits in bytes; UploadMaxFiles caps the files in one request
+	// and UploadMaxPixels the width × height of an image.
+	UploadMaxFileSize    int64 `mapstructure:"UPLOAD_MAX_FILE_SIZE"`
+	UploadMaxRequestSize int64 `mapstructure:"UPLOAD_MAX_REQUEST_SIZE"`
+	UploadMaxFiles       int   `mapstructure:"UPLOAD_MAX_FILES"`
+	UploadMaxPixels      int64 `mapstructure:"UPLOAD_MAX_PIXELS"`
+
 	ClientOrigin string `mapstructure:"CLIENT_ORIGIN"`
 	ServerOrigin string `mapstructure:"SERVER_ORIGIN"`
+	// TrustedProxies lists the addresses or CIDRs, comma-separated, whose
+	// X-Forwarded-For header is believed when working out a client's IP.
+	// Empty trusts none, so the IP is that of the connecting peer.
+	TrustedProxies string `mapstructure:"TRUSTED_PROXIES"`
 
 	AccessTokenPrivateKey  string        `mapstructure:"ACCESS_TOKEN_PRIVATE_KEY"`
 	AccessTokenPublicKey   string        `mapstructure:"ACCESS_TOKEN_PUBLIC_KEY"`
@@ -31,6 +74,52 @@ type Config struct {
 	RefreshTokenExpiresIn  time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRED_IN"`
 	AccessTokenMaxAge      int           `mapstructure:"ACCESS_TOKEN_MAXAGE"`
 	RefreshTokenMaxAge     int           `mapstructure:"REFRESH_TOKEN_MAXAGE"`
+
+	// AccessTokenKeys is a JSON array of keyring.KeySpec for rotating the
+	// access token keys; when empty AccessTokenPrivateKey and
+	// AccessTokenPublicKey are the only key. Keys are published and accepted
+	// for AccessTokenKeyOverlap (at least AccessTokenExpiresIn) around their
+	// signing period.
+	AccessTokenKeys       string        `mapstructure:"ACCESS_TOKEN_KEYS"`
+	AccessTokenKeyOverlap time.Duration `mapstructure:"ACCESS_TOKEN_KEY_OVERLAP"`
+
+	PasswordResetExpiresIn time.Duration `mapstructure:"PASSWORD_RESET_EXPIRED_IN"`
+
+	EmailVerificationExpiresIn      time.Duration `mapstructure:"EMAIL_VERIFICATION_EXPIRED_IN"`
+	EmailVerificationResendCooldown time.Duration `mapstructure:"EMAIL_VERIFICATION_RESEND_COOLDOWN"`
+	// How long a new account may sign in before verifying its email. Zero
+	// blocks unverified accounts straight away.
+	EmailVerificationGracePeriod time.Duration `mapstructure:"EMAIL_VERIFICATION_GRACE_PERIOD"`
+
+	// Failed sign-ins are counted per account and per client IP within
+	// LoginFailureWindow; past the free attempts each further one waits
+	// longer, and LoginLockoutThreshold failures lock the account.
+	// LoginLimiterDriver is "database" (shared by all instances) or "memory".
+	LoginLimiterDriver    string        `mapstructure:"LOGIN_LIMITER_DRIVER"`
+	LoginFailureWindow    time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	LoginFreeAttempts     int           `mapstructure:"LOGIN_FREE_ATTEMPTS"`
+	LoginIPFreeAttempts   int           `mapstructure:"LOGIN_IP_FREE_ATTEMPTS"`
+	LoginDelayBase        time.Duration `mapstructure:"LOGIN_DELAY_BASE"`
+	LoginDelayMax         time.Duration `mapstructure:"LOGIN_DELAY_MAX"`
+	LoginLockoutThreshold int           `mapstructure:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginLockoutDuration  time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+
+	// Two-factor authentication. MfaRequiredRoles is a comma-separated list of
+	// roles that must set it up before they can sign in, e.g. "admin,operator".
+	MfaIssuer             string        `mapstructure:"MFA_ISSUER"`
+	MfaRequiredRoles      string        `mapstructure:"MFA_REQUIRED_ROLES"`
+	MfaChallengeExpiresIn time.Duration `mapstructure:"MFA_CHALLENGE_EXPIRED_IN"`
+
+	// Sign-in with an OpenID Connect provider, off unless OidcIssuer is set.
+	// OidcProvider names it, e.g. "google", and becomes User.Provider for the
+	// accounts it creates.
+	OidcProvider     string        `mapstructure:"OIDC_PROVIDER"`
+	OidcIssuer       string        `mapstructure:"OIDC_ISSUER"`
+	OidcClientID     string        `mapstructure:"OIDC_CLIENT_ID"`
+	OidcClientSecret string        `mapstructure:"OIDC_CLIENT_SECRET"`
+	OidcRedirectURL  string        `mapstructure:"OIDC_REDIRECT_URL"`
+	OidcScopes       string        `mapstructure:"OIDC_SCOPES"`
+	OidcLoginTimeout time.Duration `mapstructure:"OIDC_LOGIN_TIMEOUT"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -38,6 +127,55 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigType("env")
 	viper.SetConfigName("app")
 
+	viper.SetDefault("APP_ENV", "production")
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("MAIL_DRIVER", "smtp")
+	viper.SetDefault("MAIL_FILE_DIR", "mail")
+	viper.SetDefault("OUTBOX_POLL_INTERVAL", "5s")
+	viper.SetDefault("OUTBOX_MAX_ATTEMPTS", 8)
+	viper.SetDefault("OUTBOX_RETRY_BASE_DELAY", "30s")
+	viper.SetDefault("OUTBOX_RETRY_MAX_DELAY", "1h")
+	viper.SetDefault("HEARTBEAT_INTERVAL", "1m")
+	viper.SetDefault("HEARTBEAT_MISSED_LIMIT", 3)
+	viper.SetDefault("HEARTBEAT_RETENTION", "720h")
+	viper.SetDefault("STORAGE_DRIVER", "local")
+	viper.SetDefault("STORAGE_LOCAL_DIR", "uploads")
+	viper.SetDefault("S3_ENDPOINT", "")
+	viper.SetDefault("S3_REGION", "us-east-1")
+	viper.SetDefault("S3_BUCKET", "")
+	viper.SetDefault("S3_ACCESS_KEY", "")
+	viper.SetDefault("S3_SECRET_KEY", "")
+	viper.SetDefault("S3_PUBLIC_URL", "")
+	viper.SetDefault("S3_PATH_STYLE", false)
+	viper.SetDefault("UPLOAD_MAX_FILE_SIZE", 10<<20)
+	viper.SetDefault("UPLOAD_MAX_REQUEST_SIZE", 50<<20)
+	viper.SetDefault("UPLOAD_MAX_FILES", 20)
+	viper.SetDefault("UPLOAD_MAX_PIXELS", 50_000_000)
+	viper.SetDefault("ACCESS_TOKEN_KEYS", "")
+	viper.SetDefault("ACCESS_TOKEN_KEY_OVERLAP", "1h")
+	viper.SetDefault("PASSWORD_RESET_EXPIRED_IN", "15m")
+	viper.SetDefault("EMAIL_VERIFICATION_EXPIRED_IN", "24h")
+	viper.SetDefault("EMAIL_VERIFICATION_RESEND_COOLDOWN", "1m")
+	viper.SetDefault("EMAIL_VERIFICATION_GRACE_PERIOD", "0s")
+	viper.SetDefault("LOGIN_LIMITER_DRIVER", "database")
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "15m")
+	viper.SetDefault("LOGIN_FREE_ATTEMPTS", 3)
+	viper.SetDefault("LOGIN_IP_FREE_ATTEMPTS", 20)
+	viper.SetDefault("LOGIN_DELAY_BASE", "1s")
+	viper.SetDefault("LOGIN_DELAY_MAX", "5m")
+	viper.SetDefault("LOGIN_LOCKOUT_THRESHOLD", 10)
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "30m")
+	viper.SetDefault("MFA_ISSUER", "OTTB")
+	viper.SetDefault("MFA_REQUIRED_ROLES", "")
+	viper.SetDefault("MFA_CHALLENGE_EXPIRED_IN", "5m")
+	viper.SetDefault("OIDC_PROVIDER", "oidc")
+	viper.SetDefault("OIDC_ISSUER", "")
+	viper.SetDefault("OIDC_CLIENT_ID", "")
+	viper.SetDefault("OIDC_CLIENT_SECRET", "")
+	viper.SetDefault("OIDC_REDIRECT_URL", "")
+	viper.SetDefault("OIDC_SCOPES", "openid email profile")
+	viper.SetDefault("OIDC_LOGIN_TIMEOUT", "10m")
+
 	viper.AutomaticEnv()
 
 	err = viper.ReadInConfig()
@@ -0,0 +1,77 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// KeySpec is how a key is configured: base64-encoded PEM like the
+// ACCESS_TOKEN_PRIVATE_KEY setting, and its RFC 3339 schedule. PublicKey
+// may be left out when PrivateKey is given, and PrivateKey for keys that
+// only verify.
+type KeySpec struct {
+	ID         string    `json:"kid"`
+	PrivateKey string    `json:"private_key"`
+	PublicKey  string    `json:"public_key"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+}
+
+// ParseKeys decodes a JSON array of KeySpec.
+func ParseKeys(raw string) ([]Key, error) {
+	var specs []KeySpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("keyring: %w", err)
+	}
+
+	keys := make([]Key, 0, len(specs))
+	for _, spec := range specs {
+		key, err := DecodeKey(spec.PrivateKey, spec.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: key %q: %w", spec.ID, err)
+		}
+		key.ID = spec.ID
+		key.NotBefore = spec.NotBefore
+		key.NotAfter = spec.NotAfter
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// DecodeKey decodes a key pair given as base64-encoded PEM. Either half may
+// be empty.
+func DecodeKey(privateKey string, publicKey string) (Key, error) {
+	var key Key
+	if privateKey != "" {
+		decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
+		if err != nil {
+			return key, fmt.Errorf("could not decode private key: %w", err)
+		}
+		if key.Private, err = jwt.ParseRSAPrivateKeyFromPEM(decodedPrivateKey); err != nil {
+			return key, fmt.Errorf("parse private key: %w", err)
+		}
+		key.Public = &key.Private.PublicKey
+	}
+	if publicKey != "" {
+		decodedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
+		if err != nil {
+			return key, fmt.Errorf("could not decode public key: %w", err)
+		}
+		public, err := jwt.ParseRSAPublicKeyFromPEM(decodedPublicKey)
+		if err != nil {
+			return key, fmt.Errorf("parse public key: %w", err)
+		}
+		if key.Public != nil && !key.Public.Equal(public) {
+			return key, fmt.Errorf("public key does not match the private key")
+		}
+		key.Public = public
+	}
+	if key.Public == nil {
+		return key, fmt.Errorf("no key given")
+	}
+	return key, nil
+}
@@ -0,0 +1,45 @@
+package keyring
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// JSONWebKey is an RSA public key as published at /.well-known/jwks.json
+// (RFC 7517).
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+func publicJWK(kid string, key *rsa.PublicKey) JSONWebKey {
+	n, e := encodePublic(key)
+	return JSONWebKey{Kty: "RSA", Use: "sig", Alg: "RS256", Kid: kid, N: n, E: e}
+}
+
+func encodePublic(key *rsa.PublicKey) (n, e string) {
+	return base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+}
+
+// Thumbprint is the RFC 7638 thumbprint of key, which stays the same for as
+// long as the key does.
+func Thumbprint(key *rsa.PublicKey) string {
+	n, e := encodePublic(key)
+	// The members must be in lexical order with no whitespace; encoding/json
+	// sorts map keys.
+	canonical, _ := json.Marshal(map[string]string{"e": e, "kty": "RSA", "n": n})
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,184 @@
+// Package keyring signs and verifies access tokens with a set of RSA keys
+// that can be rotated without logging anyone out. Every key has a schedule:
+// the newest key whose period has started signs new tokens, and each key is
+// published and accepted for an overlap before its period starts and after
+// it ends, so verifiers learn about a key before it is used and tokens it
+// signed stay valid until they expire.
+package keyring
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrNoSigningKey = errors.New("no signing key is active")
+
+// Key is one RSA key pair and the period in which it signs tokens. A zero
+// NotBefore or NotAfter leaves that end open.
+type Key struct {
+	ID string
+	// Private is nil for keys kept only to verify tokens they signed before.
+	Private   *rsa.PrivateKey
+	Public    *rsa.PublicKey
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// signs reports whether k should sign tokens at now.
+func (k Key) signs(now time.Time) bool {
+	return k.Private != nil &&
+		(k.NotBefore.IsZero() || !now.Before(k.NotBefore)) &&
+		(k.NotAfter.IsZero() || now.Before(k.NotAfter))
+}
+
+// verifies reports whether tokens signed by k are accepted at now, which is
+// also when k is published.
+func (k Key) verifies(now time.Time, overlap time.Duration) bool {
+	return (k.NotBefore.IsZero() || !now.Before(k.NotBefore.Add(-overlap))) &&
+		(k.NotAfter.IsZero() || now.Before(k.NotAfter.Add(overlap)))
+}
+
+type Ring struct {
+	keys    []Key
+	overlap time.Duration
+}
+
+// New builds a ring from keys, which are parsed once and kept for the life of
+// the process. Keys without an ID are named after their RFC 7638 thumbprint.
+func New(keys []Key, overlap time.Duration) (*Ring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keyring: no keys")
+	}
+
+	seen := make(map[string]bool, len(keys))
+	ring := &Ring{keys: make([]Key, 0, len(keys)), overlap: overlap}
+	for _, key := range keys {
+		if key.Public == nil && key.Private != nil {
+			key.Public = &key.Private.PublicKey
+		}
+		if key.Public == nil {
+			return nil, fmt.Errorf("keyring: key %q has no public key", key.ID)
+		}
+		if key.ID == "" {
+			key.ID = Thumbprint(key.Public)
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("keyring: duplicate key id %q", key.ID)
+		}
+		if !key.NotBefore.IsZero() && !key.NotAfter.IsZero() && !key.NotBefore.Before(key.NotAfter) {
+			return nil, fmt.Errorf("keyring: key %q ends before it starts", key.ID)
+		}
+		seen[key.ID] = true
+		ring.keys = append(ring.keys, key)
+	}
+	return ring, nil
+}
+
+// Signer is the key that signs tokens at now: of the keys whose period has
+// started and not ended, the one that started last.
+func (r *Ring) Signer(now time.Time) (Key, error) {
+	var signer *Key
+	for i := range r.keys {
+		key := &r.keys[i]
+		if !key.signs(now) {
+			continue
+		}
+		if signer == nil || key.NotBefore.After(signer.NotBefore) {
+			signer = key
+		}
+	}
+	if signer == nil {
+		return Key{}, ErrNoSigningKey
+	}
+	return *signer, nil
+}
+
+// Sign signs claims with the current signing key and names it in the kid
+// header.
+func (r *Ring) Sign(claims jwt.Claims) (string, error) {
+	return r.sign(claims, time.Now())
+}
+
+func (r *Ring) sign(claims jwt.Claims, now time.Time) (string, error) {
+	key, err := r.Signer(now)
+	if err != nil {
+		return "", fmt.Errorf("create: %w", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.Private)
+	if err != nil {
+		return "", fmt.Errorf("create: sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Parse verifies token against the key named by its kid header and returns
+// its claims. Tokens from before the ring have no kid and are tried against
+// every key that is currently accepted.
+func (r *Ring) Parse(token string) (jwt.MapClaims, error) {
+	return r.parse(token, time.Now())
+}
+
+func (r *Ring) parse(token string, now time.Time) (jwt.MapClaims, error) {
+	unverified, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("validate: %w", err)
+	}
+	kid, _ := unverified.Header["kid"].(string)
+
+	err = errors.New("unknown signing key")
+	for _, key := range r.keys {
+		if (kid != "" && key.ID != kid) || !key.verifies(now, r.overlap) {
+			continue
+		}
+
+		var claims jwt.MapClaims
+		claims, err = parseWith(token, key.Public)
+		if err == nil {
+			return claims, nil
+		}
+	}
+	return nil, fmt.Errorf("validate: %w", err)
+}
+
+func parseWith(token string, key *rsa.PublicKey) (jwt.MapClaims, error) {
+	parsedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected method: %s", t.Header["alg"])
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok || !parsedToken.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// JWKS is the public half of every key accepted at now, including the next
+// key before it starts signing.
+func (r *Ring) JWKS(now time.Time) JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range r.keys {
+		if key.verifies(now, r.overlap) {
+			set.Keys = append(set.Keys, publicJWK(key.ID, key.Public))
+		}
+	}
+	return set
+}
+
+// Overlap is how long keys are published before they start signing and
+// accepted after they stop.
+func (r *Ring) Overlap() time.Duration {
+	return r.overlap
+}
@@ -0,0 +1,217 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const overlap = 15 * time.Minute
+
+var (
+	testKeysOnce sync.Once
+	testKeys     [3]*rsa.PrivateKey
+)
+
+// rsaKeys generates the test keys once; RSA key generation is slow.
+func rsaKeys(t *testing.T) [3]*rsa.PrivateKey {
+	testKeysOnce.Do(func() {
+		for i := range testKeys {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testKeys[i] = key
+		}
+	})
+	return testKeys
+}
+
+// rotation is a ring handing over from key "old" to key "new" at switchAt.
+func rotation(t *testing.T, switchAt time.Time) *Ring {
+	keys := rsaKeys(t)
+	ring, err := New([]Key{
+		{ID: "old", Private: keys[0], NotAfter: switchAt},
+		{ID: "new", Private: keys[1], NotBefore: switchAt},
+	}, overlap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func claims() jwt.MapClaims {
+	return jwt.MapClaims{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func kids(set JSONWebKeySet) string {
+	var ids []string
+	for _, key := range set.Keys {
+		ids = append(ids, key.Kid)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestRotation(t *testing.T) {
+	switchAt := time.Now().Add(time.Hour)
+	ring := rotation(t, switchAt)
+
+	for _, test := range []struct {
+		name      string
+		at        time.Time
+		signer    string
+		published string
+	}{
+		{"before the overlap", switchAt.Add(-20 * time.Minute), "old", "old"},
+		{"overlap before the switch", switchAt.Add(-10 * time.Minute), "old", "old,new"},
+		{"at the switch", switchAt, "new", "old,new"},
+		{"overlap after the switch", switchAt.Add(10 * time.Minute), "new", "old,new"},
+		{"after the overlap", switchAt.Add(20 * time.Minute), "new", "new"},
+	} {
+		signer, err := ring.Signer(test.at)
+		if err != nil || signer.ID != test.signer {
+			t.Errorf("%s: signer %q, %v; want %q", test.name, signer.ID, err, test.signer)
+		}
+		if published := kids(ring.JWKS(test.at)); published != test.published {
+			t.Errorf("%s: published %q, want %q", test.name, published, test.published)
+		}
+	}
+}
+
+func TestParseDuringRotation(t *testing.T) {
+	switchAt := time.Now().Add(time.Hour)
+	ring := rotation(t, switchAt)
+
+	oldToken, err := ring.sign(claims(), switchAt.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := ring.sign(claims(), switchAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		token string
+		at    time.Time
+		ok    bool
+	}{
+		{"old key in its period", oldToken, switchAt.Add(-time.Minute), true},
+		{"old key during the overlap", oldToken, switchAt.Add(10 * time.Minute), true},
+		{"old key after the overlap", oldToken, switchAt.Add(20 * time.Minute), false},
+		{"new key before the overlap", newToken, switchAt.Add(-20 * time.Minute), false},
+		{"new key during the overlap", newToken, switchAt.Add(-10 * time.Minute), true},
+		{"new key in its period", newToken, switchAt.Add(time.Hour), true},
+	} {
+		parsed, err := ring.parse(test.token, test.at)
+		if test.ok && (err != nil || parsed["sub"] != "user") {
+			t.Errorf("%s: got %v, %v; want it accepted", test.name, parsed, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%s: accepted", test.name)
+		}
+	}
+}
+
+func TestParseKid(t *testing.T) {
+	keys := rsaKeys(t)
+	switchAt := time.Now().Add(time.Hour)
+	ring := rotation(t, switchAt)
+	now := switchAt.Add(-time.Minute)
+
+	sign := func(key *rsa.PrivateKey, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	for _, test := range []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"unknown kid", sign(keys[2], "other"), false},
+		{"kid of another key", sign(keys[2], "old"), false},
+		{"kid of the wrong ring key", sign(keys[0], "new"), false},
+		// Tokens from before the ring are tried against every accepted key
+		{"no kid, ring key", sign(keys[0], ""), true},
+		{"no kid, upcoming ring key", sign(keys[1], ""), true},
+		{"no kid, unknown key", sign(keys[2], ""), false},
+	} {
+		_, err := ring.parse(test.token, now)
+		if test.ok != (err == nil) {
+			t.Errorf("%s: got %v, want accepted %v", test.name, err, test.ok)
+		}
+	}
+
+	// Only RS256 tokens are accepted, whatever the key
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims()).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.parse(hmac, now); err == nil {
+		t.Error("accepted an HS256 token")
+	}
+	if _, err := ring.parse("not a token", now); err == nil {
+		t.Error("accepted garbage")
+	}
+}
+
+func TestNew(t *testing.T) {
+	keys := rsaKeys(t)
+
+	ring, err := New([]Key{{Private: keys[0]}}, overlap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer, _ := ring.Signer(time.Now()); signer.ID != Thumbprint(&keys[0].PublicKey) {
+		t.Errorf("got kid %q, want the key's thumbprint", signer.ID)
+	}
+
+	now := time.Now()
+	for name, keys := range map[string][]Key{
+		"no keys":        nil,
+		"no public key":  {{ID: "a"}},
+		"duplicate kid":  {{ID: "a", Private: keys[0]}, {ID: "a", Private: keys[1]}},
+		"ends too early": {{ID: "a", Private: keys[0], NotBefore: now, NotAfter: now}},
+	} {
+		if _, err := New(keys, overlap); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	// A ring of keys that only verify cannot sign
+	ring, err = New([]Key{{ID: "a", Public: &keys[0].PublicKey}}, overlap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Sign(claims()); err == nil {
+		t.Error("signed without a private key")
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	// The example key of RFC 7638, section 3.1
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+	if got, want := Thumbprint(key), "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package lockout
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mliem2k/ottb-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DatabaseStore keeps counters in the login_failures table, shared by all
+// instances.
+type DatabaseStore struct {
+	DB *gorm.DB
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+func NewDatabaseStore(db *gorm.DB) *DatabaseStore {
+	return &DatabaseStore{DB: db}
+}
+
+func (s *DatabaseStore) Claim(key string, seen Counter, now time.Time, window time.Duration) (bool, error) {
+	s.prune(now, window)
+
+	// Each statement only changes the row if it still holds what was seen,
+	// so of several concurrent claims only one succeeds
+	var result *gorm.DB
+	if seen.LastFailedAt.IsZero() {
+		// No row, or one whose failures have all expired
+		result = s.DB.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":       1,
+				"last_failed_at": now,
+			}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Lt{Column: clause.Column{Table: "login_failures", Name: "last_failed_at"}, Value: now.Add(-window)},
+			}},
+		}).Create(&models.LoginFailure{Key: key, Failures: 1, LastFailedAt: now})
+	} else {
+		result = s.DB.Model(&models.LoginFailure{}).
+			Where("key = ? AND failures = ? AND last_failed_at = ?", key, seen.Failures, seen.LastFailedAt).
+			Updates(map[string]interface{}{
+				"failures":       gorm.Expr("failures + 1"),
+				"last_failed_at": now,
+			})
+	}
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *DatabaseStore) Get(key string, now time.Time, window time.Duration) (Counter, error) {
+	var failure models.LoginFailure
+	err := s.DB.First(&failure, "key = ? AND last_failed_at >= ?", key, now.Add(-window)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Counter{}, nil
+	} else if err != nil {
+		return Counter{}, err
+	}
+	return Counter{Failures: failure.Failures, LastFailedAt: failure.LastFailedAt}, nil
+}
+
+func (s *DatabaseStore) Release(key string) error {
+	return s.DB.Model(&models.LoginFailure{}).
+		Where("key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+func (s *DatabaseStore) Reset(key string) error {
+	return s.DB.Delete(&models.LoginFailure{}, "key = ?", key).Error
+}
+
+// prune drops stale counters, at most once per window from this instance.
+func (s *DatabaseStore) prune(now time.Time, window time.Duration) {
+	s.mu.Lock()
+	if now.Sub(s.prunedAt) < window {
+		s.mu.Unlock()
+		return
+	}
+	s.prunedAt = now
+	s.mu.Unlock()
+
+	s.DB.Where("last_failed_at < ?", now.Add(-window)).Delete(&models.LoginFailure{})
+}
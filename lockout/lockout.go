@@ -0,0 +1,136 @@
+// Package lockout slows down password guessing: it counts failed sign-ins per
+// account and per client IP and makes each further attempt wait longer.
+// Counters live in memory for a single instance or in the database when
+// several instances share the load.
+package lockout
+
+import (
+	"time"
+)
+
+// Counter is the failures recorded for one key.
+type Counter struct {
+	Failures     int
+	LastFailedAt time.Time
+}
+
+// Store keeps the counters. Failures older than window no longer count: the
+// counter starts over at the next failure.
+type Store interface {
+	Get(key string, now time.Time, window time.Duration) (Counter, error)
+	// Claim counts a failure at now, but only if the counter is still seen,
+	// and reports whether it did. Concurrent claims on the same counter
+	// therefore cannot all succeed.
+	Claim(key string, seen Counter, now time.Time, window time.Duration) (bool, error)
+	// Release takes back one claimed failure.
+	Release(key string) error
+	Reset(key string) error
+}
+
+// Policy says how long to wait after a number of failures: nothing for the
+// first FreeAttempts, then BaseDelay doubling with every failure up to
+// MaxDelay.
+type Policy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+}
+
+// Delay is the wait after failures failed attempts.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures < p.FreeAttempts || p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts; i < failures; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// Limiter applies one policy to accounts and another, usually more lenient
+// since many people may share an address, to client IPs.
+type Limiter struct {
+	Store   Store
+	Window  time.Duration
+	Account Policy
+	IP      Policy
+}
+
+func accountKey(account string) string { return "account:" + account }
+func ipKey(ip string) string           { return "ip:" + ip }
+
+// maxClaimTries bounds how often a claim is retried after losing to a
+// concurrent attempt.
+const maxClaimTries = 5
+
+// Attempt lets a sign-in to account from ip go ahead, or returns how long it
+// has to wait after earlier failures. An attempt that goes ahead is counted
+// as failed before the password is even checked, so that concurrent guesses
+// cannot all slip through before any of them has failed; failures is then
+// the account's count including it. Call Succeed or Cancel when it turns out
+// not to have failed.
+func (l *Limiter) Attempt(account, ip string, now time.Time) (wait time.Duration, failures int, err error) {
+	wait, failures, err = l.claim(accountKey(account), l.Account, now)
+	if err != nil || wait > 0 {
+		return wait, 0, err
+	}
+	if wait, _, err = l.claim(ipKey(ip), l.IP, now); err != nil || wait > 0 {
+		if err := l.Store.Release(accountKey(account)); err != nil {
+			return 0, 0, err
+		}
+		return wait, 0, err
+	}
+	return 0, failures, nil
+}
+
+// claim counts an attempt against key once policy allows another one.
+func (l *Limiter) claim(key string, policy Policy, now time.Time) (time.Duration, int, error) {
+	for try := 0; try < maxClaimTries; try++ {
+		counter, err := l.Store.Get(key, now, l.Window)
+		if err != nil {
+			return 0, 0, err
+		}
+		if counter.Failures > 0 {
+			if wait := counter.LastFailedAt.Add(policy.Delay(counter.Failures)).Sub(now); wait > 0 {
+				return wait, 0, nil
+			}
+		}
+
+		claimed, err := l.Store.Claim(key, counter, now, l.Window)
+		if err != nil {
+			return 0, 0, err
+		}
+		if claimed {
+			return 0, counter.Failures + 1, nil
+		}
+	}
+	// Other attempts keep getting there first, so this one can wait
+	return time.Second, 0, nil
+}
+
+// Cancel takes back an attempt that never got as far as checking a password.
+func (l *Limiter) Cancel(account, ip string) error {
+	if err := l.Store.Release(accountKey(account)); err != nil {
+		return err
+	}
+	return l.Store.Release(ipKey(ip))
+}
+
+// Succeed forgets the account's failures after a successful attempt. Those of
+// the IP stay, so that signing in to an account of one's own does not make
+// guessing others' passwords any faster; only this attempt is taken back.
+func (l *Limiter) Succeed(account, ip string) error {
+	if err := l.Store.Reset(accountKey(account)); err != nil {
+		return err
+	}
+	return l.Store.Release(ipKey(ip))
+}
+
+// Reset forgets the account's failures, e.g. once it has been locked.
+func (l *Limiter) Reset(account string) error {
+	return l.Store.Reset(accountKey(account))
+}
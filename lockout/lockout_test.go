@@ -0,0 +1,91 @@
+package lockout
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newLimiter() *Limiter {
+	return &Limiter{
+		Store:   NewMemoryStore(),
+		Window:  15 * time.Minute,
+		Account: Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute},
+		IP:      Policy{FreeAttempts: 20, BaseDelay: time.Second, MaxDelay: time.Minute},
+	}
+}
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{FreeAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for failures, want := range []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := policy.Delay(failures); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestConcurrentAttempts(t *testing.T) {
+	limiter := newLimiter()
+	now := time.Now()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, _, err := limiter.Attempt("alice", "192.0.2.1", now)
+			if err != nil {
+				t.Error(err)
+			}
+			if wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 3 {
+		t.Errorf("%d concurrent attempts went ahead, want the 3 free ones", allowed)
+	}
+}
+
+func TestAttemptDelays(t *testing.T) {
+	limiter := newLimiter()
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		wait, failures, err := limiter.Attempt("alice", "192.0.2.1", now)
+		if err != nil || wait != 0 || failures != i {
+			t.Fatalf("attempt %d: got wait %v, failures %d, %v", i, wait, failures, err)
+		}
+	}
+
+	if wait, _, _ := limiter.Attempt("alice", "192.0.2.1", now.Add(500*time.Millisecond)); wait != 500*time.Millisecond {
+		t.Errorf("got wait %v, want 500ms", wait)
+	}
+	if wait, failures, _ := limiter.Attempt("alice", "192.0.2.1", now.Add(time.Second)); wait != 0 || failures != 4 {
+		t.Errorf("got wait %v, failures %d after the delay; want 0, 4", wait, failures)
+	}
+
+	// A refused attempt is not counted against the IP either
+	if counter, _ := limiter.Store.Get(ipKey("192.0.2.1"), now, limiter.Window); counter.Failures != 4 {
+		t.Errorf("IP has %d failures, want 4", counter.Failures)
+	}
+
+	// Signing in forgets the account's failures and gives the IP its attempt back
+	if err := limiter.Succeed("alice", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, failures, _ := limiter.Attempt("alice", "192.0.2.1", now.Add(time.Second)); wait != 0 || failures != 1 {
+		t.Errorf("got wait %v, failures %d after succeeding; want 0, 1", wait, failures)
+	}
+	if err := limiter.Cancel("alice", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if counter, _ := limiter.Store.Get(ipKey("192.0.2.1"), now, limiter.Window); counter.Failures != 3 {
+		t.Errorf("IP has %d failures, want 3", counter.Failures)
+	}
+}
@@ -0,0 +1,77 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps counters in this process only.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]Counter
+	prunedAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]Counter)}
+}
+
+func (s *MemoryStore) Claim(key string, seen Counter, now time.Time, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(now, window)
+	counter := s.get(key, now, window)
+	if counter.Failures != seen.Failures || !counter.LastFailedAt.Equal(seen.LastFailedAt) {
+		return false, nil
+	}
+	s.counters[key] = Counter{Failures: counter.Failures + 1, LastFailedAt: now}
+	return true, nil
+}
+
+func (s *MemoryStore) Get(key string, now time.Time, window time.Duration) (Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(key, now, window), nil
+}
+
+func (s *MemoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if counter, ok := s.counters[key]; ok && counter.Failures > 0 {
+		counter.Failures--
+		s.counters[key] = counter
+	}
+	return nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+	return nil
+}
+
+func (s *MemoryStore) get(key string, now time.Time, window time.Duration) Counter {
+	counter, ok := s.counters[key]
+	if !ok || now.Sub(counter.LastFailedAt) > window {
+		return Counter{}
+	}
+	return counter
+}
+
+// prune drops stale counters, at most once per window.
+func (s *MemoryStore) prune(now time.Time, window time.Duration) {
+	if now.Sub(s.prunedAt) < window {
+		return
+	}
+	for key, counter := range s.counters {
+		if now.Sub(counter.LastFailedAt) > window {
+			delete(s.counters, key)
+		}
+	}
+	s.prunedAt = now
+}
@@ -0,0 +1,234 @@
+// Package mailer renders emails and pages from the templates directory.
+//
+// An email named "verify" is made of:
+//
+//	email/verify.html   defines "subject" and "content" (required)
+//	email/verify.txt    defines "content" for the plain-text part (optional)
+//	email/verify.json   sample data for the preview endpoint (optional)
+//
+// The content is wrapped in layouts/email.html or layouts/email.txt, and every
+// file under partials/ with the matching extension is available to both.
+// Translations sit next to the default as email/verify.<locale>.html, e.g.
+// verify.de.html or verify.pt-BR.html.
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+var ErrTemplateNotFound = errors.New("email template not found")
+
+// Email is a rendered message.
+type Email struct {
+	Subject string
+	HTML    string
+	// Text is the plain-text alternative; empty when the template has none.
+	Text string
+}
+
+// Renderer loads templates from Dir. Templates are read on every render, so
+// edits show up without a restart.
+type Renderer struct {
+	Dir string
+}
+
+func New(dir string) *Renderer {
+	return &Renderer{Dir: dir}
+}
+
+// Default renders from the templates directory of the working directory.
+var Default = New("templates")
+
+// Render renders the email called name in the given locale with data.
+func Render(name, locale string, data interface{}) (Email, error) {
+	return Default.Render(name, locale, data)
+}
+
+// RenderPage renders a standalone HTML page template.
+func RenderPage(name string, data interface{}) (string, error) {
+	return Default.RenderPage(name, data)
+}
+
+// Render renders the email called name, using the closest translation of
+// locale that exists.
+func (r *Renderer) Render(name, locale string, data interface{}) (Email, error) {
+	htmlFile, ok := r.resolve(name, locale, ".html")
+	if !ok {
+		return Email{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	partials, err := filepath.Glob(filepath.Join(r.Dir, "partials", "*.html"))
+	if err != nil {
+		return Email{}, err
+	}
+	files := append([]string{filepath.Join(r.Dir, "layouts", "email.html")}, partials...)
+	htmlTemplate, err := htmltemplate.ParseFiles(append(files, htmlFile)...)
+	if err != nil {
+		return Email{}, err
+	}
+
+	var email Email
+	var buf bytes.Buffer
+	if err := htmlTemplate.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return Email{}, err
+	}
+	// The subject is a header, not HTML, so undo the escaping.
+	email.Subject = strings.TrimSpace(html.UnescapeString(buf.String()))
+
+	buf.Reset()
+	if err := htmlTemplate.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return Email{}, err
+	}
+	email.HTML = buf.String()
+
+	textFile, ok := r.resolve(name, locale, ".txt")
+	if !ok {
+		return email, nil
+	}
+	partials, err = filepath.Glob(filepath.Join(r.Dir, "partials", "*.txt"))
+	if err != nil {
+		return Email{}, err
+	}
+	files = append([]string{filepath.Join(r.Dir, "layouts", "email.txt")}, partials...)
+	textTemplate, err := texttemplate.ParseFiles(append(files, textFile)...)
+	if err != nil {
+		return Email{}, err
+	}
+
+	buf.Reset()
+	if err := textTemplate.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return Email{}, err
+	}
+	email.Text = buf.String()
+	return email, nil
+}
+
+// RenderPage renders the standalone HTML template at name, relative to Dir.
+func (r *Renderer) RenderPage(name string, data interface{}) (string, error) {
+	tmpl, err := htmltemplate.ParseFiles(filepath.Join(r.Dir, name))
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Names lists the emails that can be rendered.
+func (r *Renderer) Names() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(r.Dir, "email", "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+		if !strings.Contains(name, ".") { // skip translations
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Sample returns the preview data of the email called name, or nil when it
+// has none.
+func (r *Renderer) Sample(name string) (map[string]interface{}, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.Dir, "email", name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var sample map[string]interface{}
+	if err := json.Unmarshal(data, &sample); err != nil {
+		return nil, fmt.Errorf("sample data of %s: %w", name, err)
+	}
+	return sample, nil
+}
+
+// resolve finds the file of name for locale with the given extension, trying
+// the full locale ("pt-BR"), then its language ("pt"), then the default.
+func (r *Renderer) resolve(name, locale, extension string) (string, bool) {
+	if !validName(name) {
+		return "", false
+	}
+
+	candidates := []string{}
+	locale = NormalizeLocale(locale)
+	if locale != "" {
+		candidates = append(candidates, name+"."+locale)
+		if language, _, found := strings.Cut(locale, "-"); found {
+			candidates = append(candidates, name+"."+language)
+		}
+	}
+	candidates = append(candidates, name)
+
+	for _, candidate := range candidates {
+		file := filepath.Join(r.Dir, "email", candidate+extension)
+		if _, err := os.Stat(file); err == nil {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// validName keeps template names from reaching outside the email directory.
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\.`)
+}
+
+// NormalizeLocale turns a locale such as "pt_br" into "pt-BR", returning ""
+// for anything that doesn't look like a locale.
+func NormalizeLocale(locale string) string {
+	language, region, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"), "-")
+	if !isLetters(language, 2, 3) {
+		return ""
+	}
+	language = strings.ToLower(language)
+	if region == "" {
+		return language
+	}
+	if !isLetters(region, 2, 2) {
+		return language
+	}
+	return language + "-" + strings.ToUpper(region)
+}
+
+// PreferredLocale picks the first locale of an Accept-Language header.
+func PreferredLocale(acceptLanguage string) string {
+	first, _, _ := strings.Cut(acceptLanguage, ",")
+	first, _, _ = strings.Cut(first, ";")
+	return NormalizeLocale(first)
+}
+
+func isLetters(s string, min, max int) bool {
+	if len(s) < min || len(s) > max {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,264 @@
+package mailer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/gomail.v2"
+)
+
+// Transport delivers rendered emails.
+type Transport interface {
+	Send(to string, email Email) error
+}
+
+// Mailbox is a Transport that keeps what it sent so it can be inspected, e.g.
+// by integration tests.
+type Mailbox interface {
+	Transport
+	Messages() ([]Message, error)
+	Clear() error
+}
+
+// Message is an email captured by a Mailbox.
+type Message struct {
+	ID      string    `json:"id"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	HTML    string    `json:"html,omitempty"`
+	Text    string    `json:"text,omitempty"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+func newMessage(from, to string, email Email) *gomail.Message {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", from)
+	msg.SetHeader("To", to)
+	msg.SetHeader("Subject", email.Subject)
+	if email.Text != "" {
+		msg.SetBody("text/plain", email.Text)
+		msg.AddAlternative("text/html", email.HTML)
+	} else {
+		msg.SetBody("text/html", email.HTML)
+	}
+	return msg
+}
+
+// SMTPTransport sends through an SMTP server.
+type SMTPTransport struct {
+	From   string
+	Dialer *gomail.Dialer
+}
+
+func NewSMTPTransport(from, host string, port int, username, password string) *SMTPTransport {
+	return &SMTPTransport{From: from, Dialer: gomail.NewDialer(host, port, username, password)}
+}
+
+func (t *SMTPTransport) Send(to string, email Email) error {
+	return t.Dialer.DialAndSend(newMessage(t.From, to, email))
+}
+
+// FileTransport writes every email as an .eml file to Dir instead of sending
+// it, so it can be opened in a mail client.
+type FileTransport struct {
+	From string
+	Dir  string
+}
+
+func NewFileTransport(from, dir string) (*FileTransport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mail directory: %w", err)
+	}
+	return &FileTransport{From: from, Dir: dir}, nil
+}
+
+func (t *FileTransport) Send(to string, email Email) error {
+	msg := newMessage(t.From, to, email)
+	msg.SetDateHeader("Date", time.Now())
+
+	// The timestamp keeps the files in the order they were sent.
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + uuid.NewString()[:8] + ".eml"
+	file, err := os.Create(filepath.Join(t.Dir, name))
+	if err != nil {
+		return err
+	}
+	if _, err := msg.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Messages reads the captured emails back, oldest first.
+func (t *FileTransport) Messages() ([]Message, error) {
+	files, err := filepath.Glob(filepath.Join(t.Dir, "*.eml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	messages := make([]Message, 0, len(files))
+	for _, file := range files {
+		message, err := readMessage(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (t *FileTransport) Clear() error {
+	files, err := filepath.Glob(filepath.Join(t.Dir, "*.eml"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readMessage parses an .eml file written by FileTransport.
+func readMessage(file string) (Message, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Message{}, err
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(bufio.NewReader(f))
+	if err != nil {
+		return Message{}, err
+	}
+
+	decoder := new(mime.WordDecoder)
+	header := func(key string) string {
+		value, err := decoder.DecodeHeader(msg.Header.Get(key))
+		if err != nil {
+			return msg.Header.Get(key)
+		}
+		return value
+	}
+
+	message := Message{
+		ID:      strings.TrimSuffix(filepath.Base(file), ".eml"),
+		From:    header("From"),
+		To:      header("To"),
+		Subject: header("Subject"),
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		message.SentAt = date
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return Message{}, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := readBody(msg.Body, msg.Header.Get("Content-Transfer-Encoding"))
+		if err != nil {
+			return Message{}, err
+		}
+		message.setBody(mediaType, body)
+		return message, nil
+	}
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return Message{}, err
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		// multipart already undoes quoted-printable and drops the header.
+		body, err := readBody(part, part.Header.Get("Content-Transfer-Encoding"))
+		if err != nil {
+			return Message{}, err
+		}
+		message.setBody(partType, body)
+	}
+	return message, nil
+}
+
+func readBody(r io.Reader, encoding string) (string, error) {
+	if strings.EqualFold(encoding, "quoted-printable") {
+		r = quotedprintable.NewReader(r)
+	}
+	body, err := io.ReadAll(r)
+	return string(body), err
+}
+
+func (m *Message) setBody(mediaType, body string) {
+	switch mediaType {
+	case "text/html":
+		m.HTML = body
+	case "text/plain":
+		m.Text = body
+	}
+}
+
+// MemoryTransport keeps the most recent emails in memory instead of sending
+// them. It forgets them on restart.
+type MemoryTransport struct {
+	From string
+	// Limit caps how many emails are kept; older ones are dropped first.
+	Limit int
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryTransport(from string, limit int) *MemoryTransport {
+	return &MemoryTransport{From: from, Limit: limit}
+}
+
+func (t *MemoryTransport) Send(to string, email Email) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, Message{
+		ID:      uuid.NewString(),
+		From:    t.From,
+		To:      to,
+		Subject: email.Subject,
+		HTML:    email.HTML,
+		Text:    email.Text,
+		SentAt:  time.Now(),
+	})
+	if t.Limit > 0 && len(t.messages) > t.Limit {
+		t.messages = t.messages[len(t.messages)-t.Limit:]
+	}
+	return nil
+}
+
+// Messages returns the captured emails, oldest first.
+func (t *MemoryTransport) Messages() ([]Message, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Message(nil), t.messages...), nil
+}
+
+func (t *MemoryTransport) Clear() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = nil
+	return nil
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"strings"
+	// Station opening hours use IANA timezones, which the container lacks.
+	_ "time/tzdata"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/mliem2k/ottb-go/controllers"
 	"github.com/mliem2k/ottb-go/initializers"
+	"github.com/mliem2k/ottb-go/monitor"
+	"github.com/mliem2k/ottb-go/outbox"
 	"github.com/mliem2k/ottb-go/routes"
+	"github.com/mliem2k/ottb-go/upload"
 )
 
 var (
@@ -19,11 +26,32 @@ var (
 	UserController      controllers.UserController
 	UserRouteController routes.UserRouteController
 
+	MfaController      controllers.MfaController
+	MfaRouteController routes.MfaRouteController
+
 	PostController      controllers.PostController
 	PostRouteController routes.PostRouteController
 
 	StationController      controllers.StationController
 	StationRouteController routes.StationRouteController
+
+	DeviceController      controllers.DeviceController
+	DeviceRouteController routes.DeviceRouteController
+
+	ApiKeyController      controllers.ApiKeyController
+	ApiKeyRouteController routes.ApiKeyRouteController
+
+	AuditController      controllers.AuditController
+	AuditRouteController routes.AuditRouteController
+
+	JWKSController      controllers.JWKSController
+	JWKSRouteController routes.JWKSRouteController
+
+	OutboxController      controllers.OutboxController
+	OutboxRouteController routes.OutboxRouteController
+
+	DevController      controllers.DevController
+	DevRouteController routes.DevRouteController
 )
 
 func init() {
@@ -33,20 +61,64 @@ func init() {
 	}
 
 	initializers.ConnectDB(&config)
+	initializers.ConnectStorage(&config)
+	initializers.ConnectMailer(&config)
+	initializers.ConnectOIDC(&config)
+	initializers.ConnectLockout(&config)
+	initializers.ConnectKeyRing(&config)
+
+	uploads := upload.NewService(initializers.Store, upload.Limits{
+		MaxFileSize:    config.UploadMaxFileSize,
+		MaxRequestSize: config.UploadMaxRequestSize,
+		MaxFiles:       config.UploadMaxFiles,
+		MaxPixels:      config.UploadMaxPixels,
+	})
 
-	AuthController = controllers.NewAuthController(initializers.DB)
+	AuthController = controllers.NewAuthController(initializers.DB, initializers.OIDC, initializers.Logins, initializers.AccessTokens)
 	AuthRouteController = routes.NewAuthRouteController(AuthController)
 
 	UserController = controllers.NewUserController(initializers.DB)
 	UserRouteController = routes.NewRouteUserController(UserController)
 
-	PostController = controllers.NewPostController(initializers.DB)
+	MfaController = controllers.NewMfaController(initializers.DB)
+	MfaRouteController = routes.NewRouteMfaController(MfaController)
+
+	PostController = controllers.NewPostController(initializers.DB, uploads)
 	PostRouteController = routes.NewRoutePostController(PostController)
 
-	StationController = controllers.NewStationController(initializers.DB)
+	StationController = controllers.NewStationController(initializers.DB, uploads)
 	StationRouteController = routes.NewRouteStationController(StationController)
 
+	DeviceController = controllers.NewDeviceController(initializers.DB)
+	DeviceRouteController = routes.NewRouteDeviceController(DeviceController)
+
+	ApiKeyController = controllers.NewApiKeyController(initializers.DB)
+	ApiKeyRouteController = routes.NewRouteApiKeyController(ApiKeyController)
+
+	AuditController = controllers.NewAuditController(initializers.DB)
+	AuditRouteController = routes.NewRouteAuditController(AuditController)
+
+	JWKSController = controllers.NewJWKSController(initializers.AccessTokens)
+	JWKSRouteController = routes.NewRouteJWKSController(JWKSController)
+
+	OutboxController = controllers.NewOutboxController(initializers.DB)
+	OutboxRouteController = routes.NewRouteOutboxController(OutboxController)
+
+	DevController = controllers.NewDevController(initializers.Mail)
+	DevRouteController = routes.NewRouteDevController(DevController)
+
 	server = gin.Default()
+	// ctx.ClientIP() throttles sign-ins and goes into the audit log, so only
+	// believe X-Forwarded-For from our own proxies
+	var proxies []string
+	for _, proxy := range strings.Split(config.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if err := server.SetTrustedProxies(proxies); err != nil {
+		log.Fatal("🚀 Invalid TRUSTED_PROXIES", err)
+	}
 }
 
 func main() {
@@ -55,6 +127,23 @@ func main() {
 		log.Fatal("🚀 Could not load environment variables", err)
 	}
 
+	// Deliver queued email in the background for as long as the server runs
+	worker := outbox.NewWorker(initializers.DB, initializers.Mail.Send, outbox.Options{
+		PollInterval: config.OutboxPollInterval,
+		MaxAttempts:  config.OutboxMaxAttempts,
+		BaseDelay:    config.OutboxRetryBaseDelay,
+		MaxDelay:     config.OutboxRetryMaxDelay,
+	})
+	go worker.Run(context.Background())
+
+	// Flag stations whose booths stopped sending heartbeats
+	detector := monitor.NewOfflineDetector(initializers.DB, monitor.Options{
+		HeartbeatInterval: config.HeartbeatInterval,
+		MissedHeartbeats:  config.HeartbeatMissedLimit,
+		Retention:         config.HeartbeatRetention,
+	})
+	go detector.Run(context.Background())
+
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{"*", "http://localhost:3000", config.ClientOrigin}
 	//	corsConfig.AllowOrigins = []string{config.ServerOrigin}
@@ -71,11 +160,24 @@ func main() {
 
 	AuthRouteController.AuthRoute(router)
 	UserRouteController.UserRoute(router)
+	MfaRouteController.MfaRoute(router)
 	PostRouteController.PostRoute(router)
 	StationRouteController.StationRoute(router)
+	DeviceRouteController.DeviceRoute(router)
+	OutboxRouteController.OutboxRoute(router)
+	ApiKeyRouteController.ApiKeyRoute(router)
+	AuditRouteController.AuditRoute(router)
+	// Publish the access token keys where other services expect them
+	JWKSRouteController.JWKSRoute(&server.RouterGroup)
+	if config.AppEnv == "development" {
+		DevRouteController.DevRoute(router)
+	}
 
-	// Serve uploaded files for photos
-	server.Static("/uploads", "./uploads")
+	// Serve uploaded files for photos when they live on this instance's disk,
+	// which is also where ConnectStorage puts them when no driver is set
+	if config.StorageDriver == "" || config.StorageDriver == "local" {
+		server.Static("/uploads", config.StorageLocalDir)
+	}
 
 	// Serve over HTTPS
 	sslCert := "./certificate.crt"
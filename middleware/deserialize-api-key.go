@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/initializers"
+	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/utils"
+)
+
+// apiKeyUsageResolution limits how often LastUsedAt is written for a busy key.
+const apiKeyUsageResolution = time.Minute
+
+// DeserializeApiKey is DeserializeUser for routes that software may call: a
+// request carrying an API key (X-Api-Key, or a Bearer token starting with
+// models.ApiKeyPrefix) is let through as the key's user if the key holds
+// scope, and anything else goes through DeserializeUser.
+func DeserializeApiKey(scope string) gin.HandlerFunc {
+	deserializeUser := DeserializeUser()
+	return func(ctx *gin.Context) {
+		key := apiKeyFromRequest(ctx)
+		if key == "" {
+			deserializeUser(ctx)
+			return
+		}
+
+		if authenticateApiKey(ctx, key, scope) {
+			ctx.Next()
+		}
+	}
+}
+
+func apiKeyFromRequest(ctx *gin.Context) string {
+	if key := ctx.GetHeader("X-Api-Key"); key != "" {
+		return key
+	}
+	fields := strings.Fields(ctx.GetHeader("Authorization"))
+	if len(fields) == 2 && fields[0] == "Bearer" && strings.HasPrefix(fields[1], models.ApiKeyPrefix) {
+		return fields[1]
+	}
+	return ""
+}
+
+// authenticateApiKey checks key and that it holds scope, and stores the key
+// as currentApiKey and its user as currentUser. It responds itself and
+// returns false when the key may not be used.
+func authenticateApiKey(ctx *gin.Context, key string, scope string) bool {
+	now := time.Now()
+
+	var apiKey models.ApiKey
+	result := initializers.DB.First(&apiKey, "key_hash = ?", utils.HashToken(key))
+	if result.Error != nil || !apiKey.Active(now) {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "Invalid or expired API key"})
+		return false
+	}
+	if !apiKey.HasScope(scope) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "This API key lacks the " + scope + " scope"})
+		return false
+	}
+
+	var user models.User
+	if initializers.DB.First(&user, "id = ?", apiKey.UserId).Error != nil {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "the user belonging to this API key no longer exists"})
+		return false
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyUsageResolution {
+		initializers.DB.Model(&apiKey).UpdateColumn("last_used_at", now)
+	}
+
+	ctx.Set("currentApiKey", apiKey)
+	ctx.Set("currentUser", user)
+	return true
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/initializers"
+	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/utils"
+)
+
+// DeserializeDevice authenticates a station device by the key in the
+// Authorization header ("Bearer <key>") or X-Device-Key, and stores it as
+// currentDevice. An API key with the stations:heartbeat scope is accepted
+// too, as with DeserializeApiKey.
+func DeserializeDevice() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if key := apiKeyFromRequest(ctx); key != "" {
+			if authenticateApiKey(ctx, key, models.ScopeStationsHeartbeat) {
+				ctx.Next()
+			}
+			return
+		}
+
+		key := ctx.GetHeader("X-Device-Key")
+		fields := strings.Fields(ctx.GetHeader("Authorization"))
+		if len(fields) == 2 && fields[0] == "Bearer" {
+			key = fields[1]
+		}
+
+		if key == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "Missing device key"})
+			return
+		}
+
+		var device models.StationDevice
+		result := initializers.DB.First(&device, "key_hash = ? AND revoked_at IS NULL", utils.HashToken(key))
+		if result.Error != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": "Invalid device key"})
+			return
+		}
+
+		ctx.Set("currentDevice", device)
+		ctx.Next()
+	}
+}
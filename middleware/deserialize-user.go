@@ -8,7 +8,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/mliem2k/ottb-go/initializers"
 	"github.com/mliem2k/ottb-go/models"
-	"github.com/mliem2k/ottb-go/utils"
 )
 
 func DeserializeUser() gin.HandlerFunc {
@@ -31,11 +30,12 @@ func DeserializeUser() gin.HandlerFunc {
 		}
 
 		config, _ := initializers.LoadConfig(".")
-		sub, err := utils.ValidateToken(access_token, config.AccessTokenPublicKey)
+		claims, err := initializers.AccessTokens.Parse(access_token)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
 			return
 		}
+		sub := claims["sub"]
 
 		var user models.User
 		result := initializers.DB.First(&user, "id = ?", fmt.Sprint(sub))
@@ -44,6 +44,11 @@ func DeserializeUser() gin.HandlerFunc {
 			return
 		}
 
+		if user.VerificationRequired(config.EmailVerificationGracePeriod) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "Please verify your email address before continuing"})
+			return
+		}
+
 		ctx.Set("currentUser", user)
 		ctx.Next()
 	}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/models"
+)
+
+// RequireRole only lets the request through when the current user holds one of
+// the given roles. It must run after DeserializeUser.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		currentUser := ctx.MustGet("currentUser").(models.User)
+
+		for _, role := range roles {
+			if currentUser.Role == role {
+				ctx.Next()
+				return
+			}
+		}
+
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You do not have permission to perform this action"})
+	}
+}
@@ -3,9 +3,15 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/mliem2k/ottb-go/initializers"
 	"github.com/mliem2k/ottb-go/models"
+	"github.com/mliem2k/ottb-go/utils"
+	"gorm.io/gorm"
 )
 
 func init() {
@@ -19,6 +25,126 @@ func init() {
 
 func main() {
 	initializers.DB.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"")
-	initializers.DB.AutoMigrate(&models.User{}, &models.Post{}, &models.Station{})
+	initializers.DB.AutoMigrate(&models.User{}, &models.Post{}, &models.Station{}, &models.Session{}, &models.PasswordReset{}, &models.EmailVerification{}, &models.PostImage{}, &models.PostImageVariant{}, &models.PostStatusHistory{}, &models.OutboxMessage{}, &models.StationPhoto{}, &models.StationHours{}, &models.StationClosure{}, &models.StationDevice{}, &models.StationHeartbeat{}, &models.ApiKey{}, &models.OidcLogin{}, &models.UserIdentity{}, &models.MfaChallenge{}, &models.MfaRecoveryCode{}, &models.LoginFailure{}, &models.AccountUnlock{}, &models.AuditLog{})
+
+	if err := migratePostImages(initializers.DB); err != nil {
+		log.Fatal("Failed to migrate post images: ", err)
+	}
+	if err := migratePostStatus(initializers.DB); err != nil {
+		log.Fatal("Failed to migrate post status: ", err)
+	}
+	if err := migrateStationCoordinates(initializers.DB); err != nil {
+		log.Fatal("Failed to migrate station coordinates: ", err)
+	}
+
 	fmt.Println("👍 Migration complete")
 }
+
+// migratePostImages moves the comma-separated posts.image column into
+// post_images rows, then drops the column.
+func migratePostImages(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Post{}, "image") {
+		return nil
+	}
+
+	var legacyPosts []struct {
+		ID        uuid.UUID
+		Image     string
+		CreatedAt time.Time
+	}
+	if err := db.Table("posts").Select("id, image, created_at").Where("image IS NOT NULL AND image <> ''").Scan(&legacyPosts).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, post := range legacyPosts {
+			position := 0
+			for _, filename := range strings.Split(post.Image, ",") {
+				filename = strings.TrimSpace(filename)
+				if filename == "" {
+					continue
+				}
+
+				image := models.PostImage{
+					PostId:    post.ID,
+					Position:  position,
+					Filename:  filename,
+					CreatedAt: post.CreatedAt,
+				}
+				if data, err := os.ReadFile("uploads/" + filename); err == nil {
+					info := utils.InspectImage(data)
+					image.Size = info.Size
+					image.MimeType = info.MimeType
+					image.Width = info.Width
+					image.Height = info.Height
+					image.Checksum = info.Checksum
+				} else {
+					log.Println("Could not inspect", filename, "-", err)
+				}
+
+				if err := tx.Create(&image).Error; err != nil {
+					return err
+				}
+				position++
+			}
+		}
+
+		fmt.Println("👍 Moved images of", len(legacyPosts), "posts to post_images")
+		return tx.Migrator().DropColumn(&models.Post{}, "image")
+	})
+}
+
+// migratePostStatus turns the posts.developed flag into the status column,
+// then drops the flag. Posts that were not developed stay "shot".
+func migratePostStatus(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Post{}, "developed") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Table("posts").Where("developed = ?", true).Update("status", models.PostStatusDeveloped)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		fmt.Println("👍 Marked", result.RowsAffected, "developed posts with the developed status")
+		return tx.Migrator().DropColumn(&models.Post{}, "developed")
+	})
+}
+
+// migrateStationCoordinates parses the free-text stations.lat_long column into
+// the latitude and longitude columns, then drops it. Stations whose value
+// can't be parsed are logged and left without coordinates.
+func migrateStationCoordinates(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Station{}, "lat_long") {
+		return nil
+	}
+
+	var legacyStations []struct {
+		ID      uuid.UUID
+		Name    string
+		LatLong string
+	}
+	if err := db.Table("stations").Select("id, name, lat_long").Scan(&legacyStations).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		parsed := 0
+		for _, station := range legacyStations {
+			lat, lng, err := utils.ParseLatLong(station.LatLong)
+			if err != nil {
+				log.Printf("Could not parse the location %q of station %s (%s) - %v", station.LatLong, station.Name, station.ID, err)
+				continue
+			}
+			if err := tx.Table("stations").Where("id = ?", station.ID).
+				Updates(map[string]interface{}{"latitude": lat, "longitude": lng}).Error; err != nil {
+				return err
+			}
+			parsed++
+		}
+
+		fmt.Println("👍 Parsed the location of", parsed, "of", len(legacyStations), "stations")
+		return tx.Migrator().DropColumn(&models.Station{}, "lat_long")
+	})
+}
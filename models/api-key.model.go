@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ApiKeyPrefix starts every API key, which tells them apart from access
+// tokens and makes leaked ones easy to find.
+const ApiKeyPrefix = "ottbk_"
+
+// Scopes an API key can be granted. A key never allows more than the role of
+// the user it acts as.
+const (
+	ScopePostsRead         = "posts:read"
+	ScopePostsWrite        = "posts:write"
+	ScopeStationsHeartbeat = "stations:heartbeat"
+)
+
+var apiKeyScopes = map[string]bool{
+	ScopePostsRead:         true,
+	ScopePostsWrite:        true,
+	ScopeStationsHeartbeat: true,
+}
+
+// ValidApiKeyScope reports whether scope is one of the known scopes.
+func ValidApiKeyScope(scope string) bool {
+	return apiKeyScopes[scope]
+}
+
+// ApiKey lets software such as booths and lab scanners call the API as a
+// user without signing in. Only the hash of the key is stored; Prefix is kept
+// to recognise it.
+type ApiKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(20);not null;uniqueIndex" json:"prefix"`
+	KeyHash    string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	UserId     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id,omitempty"`
+	Scopes     []string   `gorm:"type:text;not null;serializer:json" json:"scopes"`
+	ExpiresAt  *time.Time `gorm:"null" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `gorm:"null" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `gorm:"null" json:"revoked_at,omitempty"`
+	CreatedBy  uuid.UUID  `gorm:"type:uuid;not null" json:"created_by,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null" json:"created_at,omitempty"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key may still be used at t.
+func (k *ApiKey) Active(t time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || t.Before(*k.ExpiresAt))
+}
+
+type CreateApiKeyInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+	// UserId is the user the key acts as; the admin creating it by default.
+	UserId    string     `json:"user_id,omitempty"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
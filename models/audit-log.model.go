@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audited actions.
+const (
+	AuditLoginFailed     = "login_failed"
+	AuditAccountLocked   = "account_locked"
+	AuditAccountUnlocked = "account_unlocked"
+	AuditMfaFailed       = "mfa_failed"
+)
+
+// AuditLog records a security-relevant event. UserId is the account it
+// concerns, if known, and ActorId whoever caused it when that is someone
+// else, such as an admin.
+type AuditLog struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	Action    string     `gorm:"type:varchar(50);not null;index" json:"action"`
+	UserId    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	ActorId   *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"`
+	Username  string     `gorm:"type:varchar(255)" json:"username,omitempty"`
+	ClientIP  string     `gorm:"type:varchar(64)" json:"client_ip,omitempty"`
+	UserAgent string     `gorm:"type:varchar(255)" json:"user_agent,omitempty"`
+	Detail    string     `gorm:"type:text" json:"detail,omitempty"`
+	CreatedAt time.Time  `gorm:"not null;index" json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerification is a one-time code mailed to confirm a user's address.
+// Only the SHA-256 hash of the code is stored.
+type EmailVerification struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
+	UserId     uuid.UUID  `gorm:"type:uuid;not null;index"`
+	CodeHash   string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt  time.Time  `gorm:"not null"`
+	ConsumedAt *time.Time `gorm:"null"`
+	CreatedAt  time.Time  `gorm:"not null"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginFailure counts recent failed sign-ins for an account or a client IP,
+// for the database-backed lockout store.
+type LoginFailure struct {
+	Key          string    `gorm:"type:varchar(255);primary_key"`
+	Failures     int       `gorm:"not null"`
+	LastFailedAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// What an MFA challenge is for: signing in with a code, or setting up
+// two-factor authentication first because the user's role requires it.
+const (
+	MfaPurposeLogin  = "login"
+	MfaPurposeEnroll = "enroll"
+)
+
+// MfaChallenge is the second step of a sign-in, started once the password
+// was right. Only the SHA-256 hash of its token is stored.
+type MfaChallenge struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
+	UserId     uuid.UUID  `gorm:"type:uuid;not null;index"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Purpose    string     `gorm:"type:varchar(20);not null"`
+	Attempts   int        `gorm:"not null;default:0"`
+	ExpiresAt  time.Time  `gorm:"not null"`
+	ConsumedAt *time.Time `gorm:"null"`
+	CreatedAt  time.Time  `gorm:"not null"`
+}
+
+// MfaRecoveryCode signs a user in once in place of a TOTP code. Only the
+// SHA-256 hash of the code is stored.
+type MfaRecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
+	UserId    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	CodeHash  string     `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time `gorm:"null"`
+	CreatedAt time.Time  `gorm:"not null"`
+}
+
+// MfaLoginInput finishes a sign-in with either a TOTP code or a recovery code.
+type MfaLoginInput struct {
+	MfaToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MfaTokenInput struct {
+	MfaToken string `json:"mfa_token" binding:"required"`
+}
+
+type MfaCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OidcLogin is a sign-in with the OIDC provider that has been started but not
+// finished. It is found again by the hash of the state sent to the provider
+// and deleted when the user comes back.
+type OidcLogin struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
+	StateHash    string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Nonce        string    `gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null"`
+	// RedirectTo is the client path to return to after signing in.
+	RedirectTo string    `gorm:"type:varchar(255)"`
+	ExpiresAt  time.Time `gorm:"not null;index"`
+	CreatedAt  time.Time `gorm:"not null"`
+}
+
+// UserIdentity links a user to their account at an OIDC provider.
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
+	UserId    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Provider  string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_user_identities_subject,priority:1"`
+	Subject   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_subject,priority:2"`
+	Email     string    `gorm:"type:varchar(255)"`
+	CreatedAt time.Time `gorm:"not null"`
+}
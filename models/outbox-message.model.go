@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery states of an outbox message.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead"
+)
+
+// OutboxMessage is an email waiting to be delivered by the outbox worker. It
+// is written in the same transaction as the change that caused it.
+type OutboxMessage struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	Recipient string    `gorm:"not null" json:"recipient"`
+	Subject   string    `gorm:"not null" json:"subject"`
+	// The bodies may hold one-time links, so they are never returned by the
+	// API and are cleared once the message is sent.
+	Body          string     `gorm:"type:text;not null" json:"-"`
+	TextBody      string     `gorm:"type:text" json:"-"`
+	Status        string     `gorm:"type:varchar(20);not null;index:idx_outbox_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_due,priority:2" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt        *time.Time `gorm:"null" json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"not null" json:"updated_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PostImage is one photo of a post, kept in the order given by Position.
+type PostImage struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	PostId    uuid.UUID `gorm:"type:uuid;not null;index" json:"post_id,omitempty"`
+	Position  int       `gorm:"not null" json:"position"`
+	Filename  string    `gorm:"not null" json:"filename,omitempty"`
+	Size      int64     `gorm:"not null" json:"size"`
+	MimeType  string    `gorm:"type:varchar(100)" json:"mime_type,omitempty"`
+	Width     int       `gorm:"null" json:"width,omitempty"`
+	Height    int       `gorm:"null" json:"height,omitempty"`
+	Checksum  string    `gorm:"type:varchar(64)" json:"checksum,omitempty"`
+	URL       string    `gorm:"-" json:"url,omitempty"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at,omitempty"`
+
+	// Taken from the photo's EXIF data, which is stripped from the stored file.
+	TakenAt      *time.Time `gorm:"null" json:"taken_at,omitempty"`
+	CameraMake   string     `gorm:"type:varchar(255)" json:"camera_make,omitempty"`
+	CameraModel  string     `gorm:"type:varchar(255)" json:"camera_model,omitempty"`
+	Orientation  int        `gorm:"null" json:"orientation,omitempty"`
+	GPSLatitude  *float64   `gorm:"null" json:"gps_latitude,omitempty"`
+	GPSLongitude *float64   `gorm:"null" json:"gps_longitude,omitempty"`
+
+	Variants []PostImageVariant `gorm:"foreignKey:PostImageId" json:"-"`
+	// VariantURLs maps each variant name, plus "original", to its public URL.
+	VariantURLs map[string]string `gorm:"-" json:"variants,omitempty"`
+}
+
+// PostImageVariant is a resized copy of a PostImage, e.g. its thumbnail.
+type PostImageVariant struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	PostImageId uuid.UUID `gorm:"type:uuid;not null;index" json:"post_image_id,omitempty"`
+	Name        string    `gorm:"type:varchar(50);not null" json:"name,omitempty"`
+	Filename    string    `gorm:"not null" json:"filename,omitempty"`
+	Size        int64     `gorm:"not null" json:"size"`
+	MimeType    string    `gorm:"type:varchar(100)" json:"mime_type,omitempty"`
+	Width       int       `gorm:"null" json:"width,omitempty"`
+	Height      int       `gorm:"null" json:"height,omitempty"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at,omitempty"`
+}
+
+type ReorderPostImagesInput struct {
+	ImageIds []uuid.UUID `json:"image_ids" binding:"required"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PostStatusHistory records one change of a post's development status.
+type PostStatusHistory struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	PostId     uuid.UUID `gorm:"type:uuid;not null;index" json:"post_id,omitempty"`
+	FromStatus string    `gorm:"type:varchar(20);not null" json:"from_status"`
+	ToStatus   string    `gorm:"type:varchar(20);not null" json:"to_status"`
+	ChangedBy  uuid.UUID `gorm:"type:uuid;not null" json:"changed_by,omitempty"`
+	Note       string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt  time.Time `gorm:"not null" json:"created_at,omitempty"`
+}
+
+func (PostStatusHistory) TableName() string {
+	return "post_status_history"
+}
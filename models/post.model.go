@@ -6,20 +6,61 @@ import (
 	"github.com/google/uuid"
 )
 
+// Development states of a roll of film, from drop-off to delivered scans.
+const (
+	PostStatusShot      = "shot"
+	PostStatusQueued    = "queued"
+	PostStatusScanning  = "scanning"
+	PostStatusDeveloped = "developed"
+	PostStatusDelivered = "delivered"
+	PostStatusFailed    = "failed"
+)
+
+// postStatusTransitions lists the states each state may move to. A failed
+// roll can be queued again, e.g. after a rescan.
+var postStatusTransitions = map[string][]string{
+	PostStatusShot:      {PostStatusQueued, PostStatusFailed},
+	PostStatusQueued:    {PostStatusScanning, PostStatusFailed},
+	PostStatusScanning:  {PostStatusDeveloped, PostStatusFailed},
+	PostStatusDeveloped: {PostStatusDelivered, PostStatusFailed},
+	PostStatusDelivered: {},
+	PostStatusFailed:    {PostStatusQueued},
+}
+
+// ValidPostStatus reports whether status is one of the known post states.
+func ValidPostStatus(status string) bool {
+	_, ok := postStatusTransitions[status]
+	return ok
+}
+
+// CanTransitionPostStatus reports whether a post may move from one state to
+// another.
+func CanTransitionPostStatus(from, to string) bool {
+	for _, next := range postStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Post struct {
 	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
 	Title     string    `gorm:"not null" json:"title,omitempty"`
-	Image     string    `gorm:"null" json:"image,omitempty"`
-	Developed bool      `gorm:"null" json:"developed,omitempty"`
+	Status    string    `gorm:"type:varchar(20);not null;default:'shot';index" json:"status,omitempty"`
 	StationId uuid.UUID `gorm:"null" json:"station_id,omitempty"`
 	UserId    uuid.UUID `gorm:"not null" json:"user_id,omitempty"`
 	CreatedAt time.Time `gorm:"not null" json:"created_at,omitempty"`
 	UpdatedAt time.Time `gorm:"not null" json:"updated_at,omitempty"`
+
+	Images      []PostImage `gorm:"foreignKey:PostId" json:"images,omitempty"`
+	StationName string      `gorm:"->;-:migration" json:"station_name,omitempty"`
+
+	History []PostStatusHistory `gorm:"foreignKey:PostId" json:"history,omitempty"`
 }
 
 type CreatePostRequest struct {
 	Title     string    `json:"title"  binding:"required"`
-	Image     string    `json:"image" binding:"required"`
 	UserId    string    `json:"user_id,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
@@ -27,8 +68,12 @@ type CreatePostRequest struct {
 
 type UpdatePost struct {
 	Title     string    `json:"title,omitempty"`
-	Image     string    `json:"image,omitempty"`
 	UserId    string    `json:"user_id,omitempty"`
 	CreateAt  time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 }
+
+type TransitionPostInput struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note,omitempty"`
+}
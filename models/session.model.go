@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a single refresh token issued to a user. The ID doubles as the
+// token's jti claim. Every refresh rotates the session: the old row is revoked
+// and points at its replacement, while all rows from one login share a FamilyId
+// so a replayed token can take the whole chain down.
+type Session struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key"`
+	UserId     uuid.UUID  `gorm:"type:uuid;not null;index"`
+	FamilyId   uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ReplacedBy *uuid.UUID `gorm:"type:uuid"`
+	UserAgent  string     `gorm:"type:varchar(255)"`
+	ClientIP   string     `gorm:"type:varchar(64)"`
+	ExpiresAt  time.Time  `gorm:"not null"`
+	RevokedAt  *time.Time `gorm:"null"`
+	CreatedAt  time.Time  `gorm:"not null"`
+	UpdatedAt  time.Time  `gorm:"not null"`
+}
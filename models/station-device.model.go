@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StationDevice is the software running in a booth. It authenticates with an
+// API key of which only the hash is stored; KeyPrefix is kept to tell keys
+// apart in listings.
+type StationDevice struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	StationId  uuid.UUID  `gorm:"type:uuid;not null;index" json:"station_id,omitempty"`
+	Name       string     `gorm:"not null" json:"name"`
+	KeyPrefix  string     `gorm:"type:varchar(20);not null" json:"key_prefix"`
+	KeyHash    string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	CreatedBy  uuid.UUID  `gorm:"type:uuid;not null" json:"created_by,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null" json:"created_at,omitempty"`
+	LastSeenAt *time.Time `gorm:"null" json:"last_seen_at,omitempty"`
+	RevokedAt  *time.Time `gorm:"null" json:"revoked_at,omitempty"`
+}
+
+// StationHeartbeat is one status report sent by a station device.
+type StationHeartbeat struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	StationId uuid.UUID `gorm:"type:uuid;not null;index:idx_station_heartbeats_station,priority:1" json:"station_id,omitempty"`
+	// Heartbeats come from a device, or from software using an API key.
+	DeviceId *uuid.UUID `gorm:"type:uuid" json:"device_id,omitempty"`
+	ApiKeyId *uuid.UUID `gorm:"type:uuid" json:"api_key_id,omitempty"`
+	// Paper and film levels are percentages.
+	PaperLevel     *int      `json:"paper_level,omitempty"`
+	FilmLevel      *int      `json:"film_level,omitempty"`
+	DiskFreeBytes  *int64    `json:"disk_free_bytes,omitempty"`
+	DiskTotalBytes *int64    `json:"disk_total_bytes,omitempty"`
+	Version        string    `gorm:"type:varchar(64)" json:"version,omitempty"`
+	ReceivedAt     time.Time `gorm:"not null;index:idx_station_heartbeats_station,priority:2" json:"received_at"`
+}
+
+type CreateStationDeviceInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type HeartbeatInput struct {
+	PaperLevel     *int   `json:"paper_level,omitempty" binding:"omitempty,min=0,max=100"`
+	FilmLevel      *int   `json:"film_level,omitempty" binding:"omitempty,min=0,max=100"`
+	DiskFreeBytes  *int64 `json:"disk_free_bytes,omitempty" binding:"omitempty,min=0"`
+	DiskTotalBytes *int64 `json:"disk_total_bytes,omitempty" binding:"omitempty,min=0"`
+	Version        string `json:"version,omitempty" binding:"max=64"`
+}
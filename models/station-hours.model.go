@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StationHours is one opening period of a station on a day of the week, in
+// the station's timezone. A period that closes at or before the time it
+// opens runs past midnight into the next day.
+type StationHours struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"-"`
+	StationId uuid.UUID `gorm:"type:uuid;not null;index" json:"-"`
+	// Weekday counts from Sunday (0) to Saturday (6), like time.Weekday.
+	Weekday int    `gorm:"not null" json:"weekday"`
+	Opens   string `gorm:"type:varchar(5);not null" json:"opens"`
+	Closes  string `gorm:"type:varchar(5);not null" json:"closes"`
+}
+
+func (StationHours) TableName() string {
+	return "station_hours"
+}
+
+// StationClosure keeps a station closed between two moments, e.g. for
+// maintenance, whatever its opening hours say.
+type StationClosure struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	StationId uuid.UUID `gorm:"type:uuid;not null;index" json:"station_id,omitempty"`
+	StartsAt  time.Time `gorm:"not null" json:"starts_at"`
+	EndsAt    time.Time `gorm:"not null;index" json:"ends_at"`
+	Reason    string    `gorm:"type:text" json:"reason,omitempty"`
+	CreatedBy uuid.UUID `gorm:"type:uuid;not null" json:"created_by,omitempty"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at,omitempty"`
+}
+
+type StationHoursInput struct {
+	Weekday int    `json:"weekday" binding:"min=0,max=6"`
+	Opens   string `json:"opens" binding:"required"`
+	Closes  string `json:"closes" binding:"required"`
+}
+
+type CreateStationClosureInput struct {
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// ValidClockTime reports whether s is a time of day written as "HH:MM".
+func ValidClockTime(s string) bool {
+	_, err := time.Parse("15:04", s)
+	return err == nil && len(s) == 5
+}
+
+// ValidTimezone reports whether name is an IANA timezone, the only kind both
+// Go and Postgres understand. "Local" is refused: it means something
+// different on every server and Postgres does not know it at all.
+func ValidTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StationPhoto is one photo in a station's gallery, kept in the order given
+// by Position.
+type StationPhoto struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
+	StationId uuid.UUID `gorm:"type:uuid;not null;index" json:"station_id,omitempty"`
+	Position  int       `gorm:"not null" json:"position"`
+	Filename  string    `gorm:"not null" json:"filename,omitempty"`
+	Size      int64     `gorm:"not null" json:"size"`
+	MimeType  string    `gorm:"type:varchar(100)" json:"mime_type,omitempty"`
+	Width     int       `gorm:"null" json:"width,omitempty"`
+	Height    int       `gorm:"null" json:"height,omitempty"`
+	Checksum  string    `gorm:"type:varchar(64)" json:"checksum,omitempty"`
+	URL       string    `gorm:"-" json:"url,omitempty"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at,omitempty"`
+}
+
+type ReorderStationPhotosInput struct {
+	PhotoIds []uuid.UUID `json:"photo_ids" binding:"required"`
+}
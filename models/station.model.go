@@ -9,24 +9,91 @@ import (
 type Station struct {
 	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id,omitempty"`
 	Name      string    `gorm:"not null" json:"name,omitempty"`
-	LatLong   string    `gorm:"not null" json:"lat_long,omitempty"`
+	Latitude  *float64  `gorm:"type:double precision;index:idx_stations_location,priority:1" json:"latitude"`
+	Longitude *float64  `gorm:"type:double precision;index:idx_stations_location,priority:2" json:"longitude"`
 	UserId    uuid.UUID `gorm:"not null" json:"user_id,omitempty"`
-	CreatedAt time.Time `gorm:"not null" json:"created_at,omitempty"`
-	UpdatedAt time.Time `gorm:"not null" json:"updated_at,omitempty"`
+	// ExternalRef identifies the station in the ops team's map, so that
+	// importing the same map twice updates stations instead of duplicating them.
+	ExternalRef *string `gorm:"type:varchar(255);uniqueIndex" json:"external_ref,omitempty"`
+	// Timezone is the IANA zone the opening hours are given in.
+	Timezone string `gorm:"type:varchar(64);not null;default:'UTC'" json:"timezone,omitempty"`
+	// CoverPhotoId is the photo shown for the station; nil without photos.
+	CoverPhotoId *uuid.UUID `gorm:"type:uuid" json:"cover_photo_id,omitempty"`
+	// LastSeenAt is the last heartbeat from any of the station's devices.
+	LastSeenAt *time.Time `gorm:"index" json:"last_seen_at,omitempty"`
+	// OfflineSince is set by the offline detector once the station has
+	// missed too many heartbeats, and cleared by the next one.
+	OfflineSince *time.Time `gorm:"index" json:"offline_since,omitempty"`
+	CreatedAt    time.Time  `gorm:"not null" json:"created_at,omitempty"`
+	UpdatedAt    time.Time  `gorm:"not null" json:"updated_at,omitempty"`
+
+	Photos   []StationPhoto `gorm:"foreignKey:StationId" json:"photos,omitempty"`
+	CoverURL string         `gorm:"-" json:"cover_url,omitempty"`
+
+	Hours []StationHours `gorm:"foreignKey:StationId" json:"hours,omitempty"`
+	// Closures only holds the closures that have not ended yet.
+	Closures []StationClosure `gorm:"foreignKey:StationId" json:"closures,omitempty"`
+	OpenNow  *bool            `gorm:"-" json:"open_now,omitempty"`
+
+	// PostCount is only set by the map exports.
+	PostCount *int64 `gorm:"->;-:migration" json:"post_count,omitempty"`
+	// DistanceKm is only set by the nearby search.
+	DistanceKm *float64 `gorm:"->;-:migration" json:"distance_km,omitempty"`
+}
+
+// OpenAt reports whether the station is open at t: no closure covers t and,
+// unless the station has no opening hours at all (it never closes), one of its
+// opening periods does. Hours and Closures must be loaded.
+func (s *Station) OpenAt(t time.Time) bool {
+	for _, closure := range s.Closures {
+		if !t.Before(closure.StartsAt) && t.Before(closure.EndsAt) {
+			return false
+		}
+	}
+	if len(s.Hours) == 0 {
+		return true
+	}
+
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := t.In(location)
+	clock := local.Format("15:04")
+	today := int(local.Weekday())
+	yesterday := (today + 6) % 7
+
+	for _, hours := range s.Hours {
+		overnight := hours.Closes <= hours.Opens
+		if hours.Weekday == today && hours.Opens <= clock && (overnight || clock < hours.Closes) {
+			return true
+		}
+		if hours.Weekday == yesterday && overnight && clock < hours.Closes {
+			return true
+		}
+	}
+	return false
 }
 
 type CreateStationRequest struct {
 	Name      string    `json:"title"  binding:"required"`
-	LatLong   string    `json:"lat_long,omitempty"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+	Timezone  string    `json:"timezone,omitempty"`
 	UserId    string    `json:"user_id,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	UpdatedAt time.Time `json:"updated_at,omitempty"`
 }
 
 type UpdateStation struct {
-	Name      string    `json:"title"  binding:"required"`
-	LatLong   string    `json:"lat_long,omitempty"`
-	UserId    string    `json:"user_id,omitempty"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Name      string   `json:"title"  binding:"required"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Timezone  *string  `json:"timezone,omitempty"`
+	// Hours, when given, replace all opening hours; an empty list means the
+	// station never closes.
+	Hours     *[]StationHoursInput `json:"hours,omitempty" binding:"omitempty,dive"`
+	UserId    string               `json:"user_id,omitempty"`
+	CreatedAt time.Time            `json:"created_at,omitempty"`
+	UpdatedAt time.Time            `json:"updated_at,omitempty"`
 }
@@ -6,6 +6,12 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleUser     = "user"
+)
+
 type User struct {
 	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key"`
 	Name      string    `gorm:"type:varchar(255);not null"`
@@ -18,6 +24,43 @@ type User struct {
 	Verified  bool      `gorm:"not null"`
 	CreatedAt time.Time `gorm:"not null"`
 	UpdatedAt time.Time `gorm:"not null"`
+
+	// NotifyOnDeveloped sends the user an email once a roll of theirs is
+	// developed.
+	NotifyOnDeveloped bool `gorm:"not null;default:true"`
+	// Locale picks the translation of emails, e.g. "de"; empty for the default.
+	Locale string `gorm:"type:varchar(20)"`
+
+	// MfaSecret is the TOTP secret, pending until MfaEnabledAt is set.
+	MfaSecret    string     `gorm:"type:varchar(64)"`
+	MfaEnabledAt *time.Time `gorm:"null"`
+	// MfaLastCounter is the time step of the last accepted code, so that a
+	// code cannot be used twice.
+	MfaLastCounter int64 `gorm:"not null;default:0"`
+
+	// LockedUntil refuses sign-ins after too many failed attempts.
+	LockedUntil *time.Time `gorm:"null"`
+}
+
+// Locked reports whether sign-ins are refused at t.
+func (u *User) Locked(t time.Time) bool {
+	return u.LockedUntil != nil && t.Before(*u.LockedUntil)
+}
+
+// MfaEnabled reports whether signing in takes a TOTP code as well.
+func (u *User) MfaEnabled() bool {
+	return u.MfaEnabledAt != nil
+}
+
+// VerificationRequired reports whether the account must verify its email
+// before it may sign in, given how long new accounts are allowed to wait.
+func (u *User) VerificationRequired(gracePeriod time.Duration) bool {
+	return !u.Verified && time.Since(u.CreatedAt) >= gracePeriod
+}
+
+// IsStaff reports whether the user manages content on behalf of others.
+func (u *User) IsStaff() bool {
+	return u.Role == RoleAdmin || u.Role == RoleOperator
 }
 
 type SignUpInput struct {
@@ -34,6 +77,23 @@ type SignInInput struct {
 	Password string `json:"password"  binding:"required"`
 }
 
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResendVerificationInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordInput struct {
+	Password        string `json:"password" binding:"required,min=8"`
+	PasswordConfirm string `json:"passwordConfirm" binding:"required"`
+}
+
+type UpdateRoleInput struct {
+	Role string `json:"role" binding:"required,oneof=admin operator user"`
+}
+
 type UserResponse struct {
 	ID        uuid.UUID `json:"id,omitempty"`
 	Name      string    `json:"name,omitempty"`
@@ -44,4 +104,11 @@ type UserResponse struct {
 	Provider  string    `json:"provider"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	NotifyOnDeveloped bool `json:"notify_on_developed"`
+	MfaEnabled        bool `json:"mfa_enabled"`
+}
+
+type UpdatePreferencesInput struct {
+	NotifyOnDeveloped *bool `json:"notify_on_developed" binding:"required"`
 }
@@ -0,0 +1,86 @@
+// Package monitor watches the booths: it flags stations whose devices have
+// stopped sending heartbeats and prunes old heartbeats.
+package monitor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mliem2k/ottb-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Options tune the detector. Zero values fall back to the defaults below.
+type Options struct {
+	// HeartbeatInterval is how often devices are expected to report; the
+	// detector checks at the same pace.
+	HeartbeatInterval time.Duration
+	// MissedHeartbeats is how many heartbeats in a row a station may miss
+	// before it is flagged offline.
+	MissedHeartbeats int
+	// Retention is how long heartbeats are kept.
+	Retention time.Duration
+}
+
+// OfflineDetector sets Station.OfflineSince on stations that went quiet.
+// Stations that never sent a heartbeat are not watched.
+type OfflineDetector struct {
+	DB      *gorm.DB
+	Options Options
+}
+
+func NewOfflineDetector(db *gorm.DB, options Options) *OfflineDetector {
+	if options.HeartbeatInterval <= 0 {
+		options.HeartbeatInterval = time.Minute
+	}
+	if options.MissedHeartbeats <= 0 {
+		options.MissedHeartbeats = 3
+	}
+	if options.Retention <= 0 {
+		options.Retention = 30 * 24 * time.Hour
+	}
+	return &OfflineDetector{DB: db, Options: options}
+}
+
+// Run checks the stations until ctx is cancelled.
+func (d *OfflineDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Options.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.RunOnce(time.Now()); err != nil {
+			log.Println("Monitor:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce flags the stations that are offline at now and returns the ones
+// that were newly flagged.
+func (d *OfflineDetector) RunOnce(now time.Time) ([]models.Station, error) {
+	cutoff := now.Add(-time.Duration(d.Options.MissedHeartbeats) * d.Options.HeartbeatInterval)
+
+	var stations []models.Station
+	err := d.DB.Model(&stations).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "name"}, {Name: "last_seen_at"}}}).
+		Where("last_seen_at < ? AND offline_since IS NULL", cutoff).
+		UpdateColumn("offline_since", now).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, station := range stations {
+		log.Println("Monitor: station", station.ID, station.Name, "is offline, last seen", station.LastSeenAt.Format(time.RFC3339))
+	}
+
+	if err := d.DB.Where("received_at < ?", now.Add(-d.Options.Retention)).Delete(&models.StationHeartbeat{}).Error; err != nil {
+		return stations, err
+	}
+	return stations, nil
+}
@@ -0,0 +1,265 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	testClientID     = "ottb-client"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "https://ottb.example.com/api/auth/oidc/callback"
+)
+
+var (
+	testKeysOnce sync.Once
+	testRSAKey   *rsa.PrivateKey
+	testECKey    *ecdsa.PrivateKey
+)
+
+// signingKeys generates the test keys once; RSA key generation is slow.
+func signingKeys(t *testing.T) (*rsa.PrivateKey, *ecdsa.PrivateKey) {
+	testKeysOnce.Do(func() {
+		var err error
+		if testRSAKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+		if testECKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return testRSAKey, testECKey
+}
+
+// mockProvider serves discovery, a key set and a token endpoint that hands
+// out idToken for code once the PKCE verifier matches its challenge.
+type mockProvider struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	keys       []map[string]string
+	keyFetches int
+	code       string
+	challenge  string
+	idToken    string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	m := &mockProvider{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                m.URL,
+			AuthorizationEndpoint: m.URL + "/authorize?prompt=select_account",
+			TokenEndpoint:         m.URL + "/token",
+			JwksURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.keyFetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": m.keys})
+	})
+	mux.HandleFunc("/token", m.token)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fail := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+	if id, secret, ok := r.BasicAuth(); !ok || id != testClientID || secret != testClientSecret {
+		fail("invalid_client")
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != testRedirectURL {
+		fail("invalid_request")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if r.PostFormValue("code") != m.code || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+		fail("invalid_grant")
+		return
+	}
+	json.NewEncoder(w).Encode(Tokens{AccessToken: "access", TokenType: "Bearer", IDToken: m.idToken, ExpiresIn: 3600})
+}
+
+// publish replaces the key set with the public halves of keys, by kid.
+func (m *mockProvider) publish(keys map[string]interface{}) {
+	encode := func(n *big.Int, size int) string {
+		return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, size)))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = nil
+	for kid, key := range keys {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			m.keys = append(m.keys, map[string]string{
+				"kty": "RSA", "kid": kid, "use": "sig",
+				"n": encode(key.N, key.Size()), "e": encode(big.NewInt(int64(key.E)), 3),
+			})
+		case *ecdsa.PrivateKey:
+			m.keys = append(m.keys, map[string]string{
+				"kty": "EC", "kid": kid, "crv": "P-256",
+				"x": encode(key.X, 32), "y": encode(key.Y, 32),
+			})
+		}
+	}
+}
+
+func (m *mockProvider) fetches() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keyFetches
+}
+
+func (m *mockProvider) provider() *Provider {
+	return NewProvider(Config{
+		Name:         "mock",
+		Issuer:       m.URL + "/",
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	})
+}
+
+// sign builds an ID token from the mock provider with claims overriding the
+// defaults; a nil claim is left out.
+func (m *mockProvider) sign(t *testing.T, key interface{}, kid string, claims jwt.MapClaims) string {
+	now := time.Now()
+	all := jwt.MapClaims{
+		"iss":   m.URL,
+		"aud":   testClientID,
+		"sub":   "1234567890",
+		"email": "ana@example.com",
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": "nonce-1",
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(all, name)
+		} else {
+			all[name] = value
+		}
+	}
+
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+	}
+	token := jwt.NewWithClaims(method, all)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockProvider(t)
+	verifier, challenge, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	if want := base64.RawURLEncoding.EncodeToString(sum[:]); challenge != want {
+		t.Errorf("got challenge %q, want %q", challenge, want)
+	}
+
+	raw, err := m.provider().AuthCodeURL(context.Background(), "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.URL+"/authorize" {
+		t.Errorf("got endpoint %q", got)
+	}
+
+	query := u.Query()
+	for name, want := range map[string]string{
+		"prompt":                "select_account",
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        challenge,
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	m := newMockProvider(t)
+	verifier, challenge, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	m.code, m.challenge, m.idToken = "code-1", challenge, "id-token"
+	m.mu.Unlock()
+	p := m.provider()
+	ctx := context.Background()
+
+	tokens, err := p.Exchange(ctx, "code-1", verifier)
+	if err != nil || tokens.IDToken != "id-token" || tokens.AccessToken != "access" {
+		t.Errorf("got %+v, %v; want the tokens", tokens, err)
+	}
+
+	if _, err := p.Exchange(ctx, "code-1", "another verifier"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("got %v for the wrong verifier, want invalid_grant", err)
+	}
+	if _, err := p.Exchange(ctx, "code-2", verifier); err == nil {
+		t.Error("exchanged an unknown code")
+	}
+
+	p.Config.ClientSecret = "wrong"
+	if _, err := p.Exchange(ctx, "code-1", verifier); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("got %v for the wrong secret, want invalid_client", err)
+	}
+
+	// A successful answer without an ID token is not a sign-in
+	m.mu.Lock()
+	m.idToken = ""
+	m.mu.Unlock()
+	p.Config.ClientSecret = testClientSecret
+	if _, err := p.Exchange(ctx, "code-1", verifier); err == nil {
+		t.Error("accepted a response without an id_token")
+	}
+}
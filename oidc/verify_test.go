@@ -0,0 +1,121 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestVerifyIDToken(t *testing.T) {
+	rsaKey, ecKey := signingKeys(t)
+	m := newMockProvider(t)
+	m.publish(map[string]interface{}{"rsa": rsaKey, "ec": ecKey})
+	p := m.provider()
+	ctx := context.Background()
+	now := time.Now()
+
+	claims, err := p.VerifyIDToken(ctx, m.sign(t, rsaKey, "rsa", jwt.MapClaims{"email_verified": "true"}), "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "1234567890" || claims.Email != "ana@example.com" || !claims.EmailVerified {
+		t.Errorf("got %+v", claims)
+	}
+
+	for _, test := range []struct {
+		name  string
+		key   interface{}
+		kid   string
+		extra jwt.MapClaims
+		nonce string
+		ok    bool
+	}{
+		{"EC key", ecKey, "ec", nil, "nonce-1", true},
+		{"audience list", rsaKey, "rsa", jwt.MapClaims{"aud": []string{"other", testClientID}}, "nonce-1", true},
+		{"expired within the clock skew", rsaKey, "rsa", jwt.MapClaims{"exp": now.Add(-clockSkew / 2).Unix()}, "nonce-1", true},
+		{"wrong issuer", rsaKey, "rsa", jwt.MapClaims{"iss": "https://evil.example.com"}, "nonce-1", false},
+		{"wrong audience", rsaKey, "rsa", jwt.MapClaims{"aud": "other"}, "nonce-1", false},
+		{"no audience", rsaKey, "rsa", jwt.MapClaims{"aud": nil}, "nonce-1", false},
+		{"wrong authorized party", rsaKey, "rsa", jwt.MapClaims{"azp": "other"}, "nonce-1", false},
+		{"wrong nonce", rsaKey, "rsa", nil, "nonce-2", false},
+		{"no nonce", rsaKey, "rsa", jwt.MapClaims{"nonce": nil}, "", false},
+		{"expired", rsaKey, "rsa", jwt.MapClaims{"exp": now.Add(-2 * clockSkew).Unix()}, "nonce-1", false},
+		{"no expiry", rsaKey, "rsa", jwt.MapClaims{"exp": nil}, "nonce-1", false},
+		{"issued in the future", rsaKey, "rsa", jwt.MapClaims{"iat": now.Add(2 * clockSkew).Unix()}, "nonce-1", false},
+		{"no subject", rsaKey, "rsa", jwt.MapClaims{"sub": nil}, "nonce-1", false},
+		{"kid of another key", ecKey, "rsa", nil, "nonce-1", false},
+		{"no kid with several keys", rsaKey, "", nil, "nonce-1", false},
+	} {
+		_, err := p.VerifyIDToken(ctx, m.sign(t, test.key, test.kid, test.extra), test.nonce)
+		if test.ok && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.ok && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want ErrInvalidToken", test.name, err)
+		}
+	}
+
+	// Only asymmetric signatures are accepted
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": m.URL, "aud": testClientID, "sub": "1", "nonce": "nonce-1", "exp": now.Add(time.Hour).Unix(),
+	}).SignedString([]byte(testClientSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.VerifyIDToken(ctx, hmac, "nonce-1"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v for an HS256 token, want ErrInvalidToken", err)
+	}
+
+	if fetches := m.fetches(); fetches != 1 {
+		t.Errorf("fetched the keys %d times, want once", fetches)
+	}
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	rsaKey, ecKey := signingKeys(t)
+	m := newMockProvider(t)
+	m.publish(map[string]interface{}{"old": rsaKey})
+	p := m.provider()
+	ctx := context.Background()
+
+	// Without a kid the only published key is used
+	if _, err := p.VerifyIDToken(ctx, m.sign(t, rsaKey, "", nil), "nonce-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider starts signing with a key it did not publish before
+	m.publish(map[string]interface{}{"old": rsaKey, "new": ecKey})
+	rotated := m.sign(t, ecKey, "new", nil)
+
+	// Unknown kids only refetch the keys once per keyRefreshInterval
+	if _, err := p.VerifyIDToken(ctx, rotated, "nonce-1"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v right after a fetch, want ErrInvalidToken", err)
+	}
+	if fetches := m.fetches(); fetches != 1 {
+		t.Errorf("fetched the keys %d times within the refresh interval, want once", fetches)
+	}
+
+	p.mu.Lock()
+	p.keysAt = p.keysAt.Add(-keyRefreshInterval)
+	p.mu.Unlock()
+	if _, err := p.VerifyIDToken(ctx, rotated, "nonce-1"); err != nil {
+		t.Errorf("got %v after the keys were fetched again, want the new key accepted", err)
+	}
+	if _, err := p.VerifyIDToken(ctx, m.sign(t, rsaKey, "old", nil), "nonce-1"); err != nil {
+		t.Errorf("got %v for the old key, want it still accepted", err)
+	}
+	if fetches := m.fetches(); fetches != 2 {
+		t.Errorf("fetched the keys %d times, want twice", fetches)
+	}
+
+	// A kid the provider does not publish at all stays unknown
+	p.mu.Lock()
+	p.keysAt = p.keysAt.Add(-keyRefreshInterval)
+	p.mu.Unlock()
+	if _, err := p.VerifyIDToken(ctx, m.sign(t, rsaKey, "unknown", nil), "nonce-1"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("got %v for an unknown kid, want ErrInvalidToken", err)
+	}
+}
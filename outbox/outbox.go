@@ -0,0 +1,210 @@
+// Package outbox delivers email reliably: messages are stored in the database
+// together with the change that caused them and sent by a background worker
+// that retries with exponential backoff.
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mliem2k/ottb-go/mailer"
+	"github.com/mliem2k/ottb-go/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Enqueue stores an email for delivery. Pass the transaction of the change the
+// email belongs to, so that the email is only sent if that change commits.
+func Enqueue(tx *gorm.DB, to string, email mailer.Email) error {
+	now := time.Now()
+	return tx.Create(&models.OutboxMessage{
+		Recipient:     to,
+		Subject:       email.Subject,
+		Body:          email.HTML,
+		TextBody:      email.Text,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}).Error
+}
+
+// Retry puts a message back in the queue to be sent straight away, with a
+// fresh set of attempts. It reports whether the message exists and was not
+// already sent.
+func Retry(db *gorm.DB, id string) (bool, error) {
+	now := time.Now()
+	result := db.Model(&models.OutboxMessage{}).
+		Where("id = ? AND status <> ?", id, models.OutboxStatusSent).
+		Updates(map[string]interface{}{
+			"status":          models.OutboxStatusPending,
+			"attempts":        0,
+			"next_attempt_at": now,
+			"updated_at":      now,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// SendFunc delivers a single email.
+type SendFunc func(to string, email mailer.Email) error
+
+// Options tune the worker. Zero values fall back to the defaults below.
+type Options struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// Lease is how long a claimed message is hidden from other workers while
+	// it is being sent.
+	Lease time.Duration
+}
+
+// Worker polls the outbox and sends due messages.
+type Worker struct {
+	DB      *gorm.DB
+	Send    SendFunc
+	Options Options
+}
+
+func NewWorker(db *gorm.DB, send SendFunc, options Options) *Worker {
+	if options.PollInterval <= 0 {
+		options.PollInterval = 5 * time.Second
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 20
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 8
+	}
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = 30 * time.Second
+	}
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = time.Hour
+	}
+	if options.Lease <= 0 {
+		options.Lease = 5 * time.Minute
+	}
+	return &Worker{DB: db, Send: send, Options: options}
+}
+
+// Run delivers messages until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			// Keep going while full batches come back, so a backlog drains
+			// without waiting for the next tick.
+			sent, err := w.RunOnce(ctx)
+			if err != nil {
+				log.Println("Outbox:", err)
+			}
+			if err != nil || sent < w.Options.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims one batch of due messages and tries to send them, returning
+// how many were claimed.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	messages, err := w.claim()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+		w.deliver(&messages[i])
+	}
+	return len(messages), nil
+}
+
+// claim locks due messages, skipping ones another worker holds, and pushes
+// their next attempt out by the lease so they are not picked up twice.
+func (w *Worker) claim() ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, now).
+			Order("next_attempt_at").
+			Limit(w.Options.BatchSize).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		ids := make([]interface{}, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+			messages[i].Attempts++
+		}
+		return tx.Model(&models.OutboxMessage{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"attempts":        gorm.Expr("attempts + 1"),
+				"next_attempt_at": now.Add(w.Options.Lease),
+				"updated_at":      now,
+			}).Error
+	})
+	return messages, err
+}
+
+func (w *Worker) deliver(message *models.OutboxMessage) {
+	err := w.Send(message.Recipient, mailer.Email{
+		Subject: message.Subject,
+		HTML:    message.Body,
+		Text:    message.TextBody,
+	})
+	now := time.Now()
+
+	updates := map[string]interface{}{"updated_at": now}
+	switch {
+	case err == nil:
+		updates["status"] = models.OutboxStatusSent
+		updates["sent_at"] = now
+		updates["body"] = ""
+		updates["text_body"] = ""
+		updates["last_error"] = ""
+	case message.Attempts >= w.Options.MaxAttempts:
+		log.Println("Outbox: giving up on message", message.ID, "after", message.Attempts, "attempts:", err)
+		updates["status"] = models.OutboxStatusDead
+		updates["last_error"] = err.Error()
+	default:
+		updates["next_attempt_at"] = now.Add(w.backoff(message.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	if err := w.DB.Model(&models.OutboxMessage{}).Where("id = ?", message.ID).Updates(updates).Error; err != nil {
+		log.Println("Outbox: could not record delivery of", message.ID, "-", err)
+	}
+}
+
+// backoff is the wait before the next attempt: BaseDelay doubled for every
+// failed attempt so far, capped at MaxDelay.
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.Options.BaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= w.Options.MaxDelay {
+			return w.Options.MaxDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/controllers"
+	"github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/models"
+)
+
+type ApiKeyRouteController struct {
+	apiKeyController controllers.ApiKeyController
+}
+
+func NewRouteApiKeyController(apiKeyController controllers.ApiKeyController) ApiKeyRouteController {
+	return ApiKeyRouteController{apiKeyController}
+}
+
+func (kc *ApiKeyRouteController) ApiKeyRoute(rg *gin.RouterGroup) {
+
+	router := rg.Group("admin/api-keys")
+	router.Use(middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin))
+	router.GET("", kc.apiKeyController.FindApiKeys)
+	router.POST("", kc.apiKeyController.CreateApiKey)
+	router.DELETE("/:apiKeyId", kc.apiKeyController.RevokeApiKey)
+}
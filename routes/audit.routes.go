@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/controllers"
+	"github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/models"
+)
+
+type AuditRouteController struct {
+	auditController controllers.AuditController
+}
+
+func NewRouteAuditController(auditController controllers.AuditController) AuditRouteController {
+	return AuditRouteController{auditController}
+}
+
+func (auc *AuditRouteController) AuditRoute(rg *gin.RouterGroup) {
+
+	router := rg.Group("admin/audit-logs")
+	router.Use(middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin))
+	router.GET("", auc.auditController.FindAuditLogs)
+}
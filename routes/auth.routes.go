@@ -18,8 +18,16 @@ func (rc *AuthRouteController) AuthRoute(rg *gin.RouterGroup) {
 	router := rg.Group("auth")
 
 	router.POST("/register", rc.authController.SignUpUser)
-	router.GET("/verifyemail/:userId", rc.authController.VerifyEmail)
+	router.GET("/verifyemail/:code", rc.authController.VerifyEmail)
+	router.POST("/resendverification", rc.authController.ResendVerification)
 	router.POST("/login", rc.authController.SignInUser)
+	router.POST("/login/mfa", rc.authController.VerifyLoginMfa)
+	router.POST("/login/mfa/enroll", rc.authController.StartLoginMfaEnrollment)
 	router.GET("/refresh", rc.authController.RefreshAccessToken)
 	router.GET("/logout", middleware.DeserializeUser(), rc.authController.LogoutUser)
+	router.POST("/forgotpassword", rc.authController.ForgotPassword)
+	router.PATCH("/resetpassword/:token", rc.authController.ResetPassword)
+	router.GET("/unlock/:token", rc.authController.UnlockAccount)
+	router.GET("/oidc/login", rc.authController.OidcLogin)
+	router.GET("/oidc/callback", rc.authController.OidcCallback)
 }
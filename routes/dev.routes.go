@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/controllers"
+)
+
+type DevRouteController struct {
+	devController controllers.DevController
+}
+
+func NewRouteDevController(devController controllers.DevController) DevRouteController {
+	return DevRouteController{devController}
+}
+
+// DevRoute must only be registered in development: the previews need no
+// authentication.
+func (dc *DevRouteController) DevRoute(rg *gin.RouterGroup) {
+
+	router := rg.Group("dev")
+	router.GET("/emails", dc.devController.FindEmailTemplates)
+	router.GET("/emails/:name", dc.devController.PreviewEmail)
+	router.GET("/mail", dc.devController.FindMail)
+	router.DELETE("/mail", dc.devController.ClearMail)
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/controllers"
+	"github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/models"
+)
+
+type DeviceRouteController struct {
+	deviceController controllers.DeviceController
+}
+
+func NewRouteDeviceController(deviceController controllers.DeviceController) DeviceRouteController {
+	return DeviceRouteController{deviceController}
+}
+
+func (dc *DeviceRouteController) DeviceRoute(rg *gin.RouterGroup) {
+
+	router := rg.Group("stations/:stationId")
+
+	// Called by the booth software itself
+	router.POST("/heartbeat", middleware.DeserializeDevice(), dc.deviceController.RecordHeartbeat)
+
+	staff := router.Group("", middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin, models.RoleOperator))
+	staff.GET("/heartbeats", dc.deviceController.FindStationHeartbeats)
+
+	admin := router.Group("devices", middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin))
+	admin.GET("", dc.deviceController.FindStationDevices)
+	admin.POST("", dc.deviceController.CreateStationDevice)
+	admin.DELETE("/:deviceId", dc.deviceController.RevokeStationDevice)
+}
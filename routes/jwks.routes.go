@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/controllers"
+)
+
+type JWKSRouteController struct {
+	jwksController controllers.JWKSController
+}
+
+func NewRouteJWKSController(jwksController controllers.JWKSController) JWKSRouteController {
+	return JWKSRouteController{jwksController}
+}
+
+// JWKSRoute is registered at the server root, where verifiers look for it.
+func (jc *JWKSRouteController) JWKSRoute(rg *gin.RouterGroup) {
+	rg.GET("/.well-known/jwks.json", jc.jwksController.FindKeys)
+}
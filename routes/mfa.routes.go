@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/controllers"
+	"github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/models"
+)
+
+type MfaRouteController struct {
+	mfaController controllers.MfaController
+}
+
+func NewRouteMfaController(mfaController controllers.MfaController) MfaRouteController {
+	return MfaRouteController{mfaController}
+}
+
+func (mc *MfaRouteController) MfaRoute(rg *gin.RouterGroup) {
+
+	router := rg.Group("users/me/mfa")
+	router.Use(middleware.DeserializeUser())
+	router.POST("", mc.mfaController.StartMfaEnrollment)
+	router.POST("/confirm", mc.mfaController.ConfirmMfaEnrollment)
+	router.DELETE("", mc.mfaController.DisableMfa)
+	router.POST("/recovery-codes", mc.mfaController.RegenerateRecoveryCodes)
+
+	rg.DELETE("/users/:userId/mfa", middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin), mc.mfaController.ResetUserMfa)
+}
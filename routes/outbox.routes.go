@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mliem2k/ottb-go/controllers"
+	"github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/models"
+)
+
+type OutboxRouteController struct {
+	outboxController controllers.OutboxController
+}
+
+func NewRouteOutboxController(outboxController controllers.OutboxController) OutboxRouteController {
+	return OutboxRouteController{outboxController}
+}
+
+func (oc *OutboxRouteController) OutboxRoute(rg *gin.RouterGroup) {
+
+	router := rg.Group("admin/outbox")
+	router.Use(middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin))
+	router.GET("", oc.outboxController.FindOutboxMessages)
+	router.POST("/:messageId/retry", oc.outboxController.RetryOutboxMessage)
+}
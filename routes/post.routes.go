@@ -3,7 +3,8 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/mliem2k/ottb-go/controllers"
-	// "github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/models"
 )
 
 type PostRouteController struct {
@@ -17,11 +18,21 @@ func NewRoutePostController(postController controllers.PostController) PostRoute
 func (pc *PostRouteController) PostRoute(rg *gin.RouterGroup) {
 
 	router := rg.Group("posts")
-	// router.Use(middleware.DeserializeUser())
-	router.POST("", pc.postController.CreatePost)
-	router.GET("", pc.postController.FindPosts)
-	router.PUT("/:postId", pc.postController.UpdatePost)
-	router.GET("/:postId", pc.postController.FindPostById)
-	router.DELETE("/:postId", pc.postController.DeletePost)
-	router.GET("/users/:userId", pc.postController.FindPostsByUserId)
+
+	// Booth and lab-scanner software may call these with a scoped API key
+	read := middleware.DeserializeApiKey(models.ScopePostsRead)
+	write := middleware.DeserializeApiKey(models.ScopePostsWrite)
+	router.POST("", write, pc.postController.CreatePost)
+	router.GET("", read, pc.postController.FindPosts)
+	router.GET("/:postId", read, pc.postController.FindPostById)
+	router.POST("/:postId/images", write, pc.postController.AddPostImages)
+	router.GET("/:postId/transitions", read, pc.postController.FindPostTransitions)
+	router.POST("/:postId/transitions", write, middleware.RequireRole(models.RoleAdmin, models.RoleOperator), pc.postController.TransitionPost)
+
+	users := router.Group("", middleware.DeserializeUser())
+	users.PUT("/:postId", pc.postController.UpdatePost)
+	users.DELETE("/:postId", pc.postController.DeletePost)
+	users.GET("/users/:userId", pc.postController.FindPostsByUserId)
+	users.PUT("/:postId/images/order", pc.postController.ReorderPostImages)
+	users.DELETE("/:postId/images/:imageId", pc.postController.DeletePostImage)
 }
@@ -3,6 +3,8 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/mliem2k/ottb-go/controllers"
+	"github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/models"
 )
 
 type StationRouteController struct {
@@ -15,11 +17,27 @@ func NewRouteStationController(stationController controllers.StationController)
 
 func (pc *StationRouteController) StationRoute(rg *gin.RouterGroup) {
 
+	// Exports for mapping tools, taking the same filters as FindStations
+	rg.GET("/stations.geojson", pc.stationController.ExportStationsGeoJSON)
+	rg.GET("/stations.kml", pc.stationController.ExportStationsKML)
+
 	router := rg.Group("stations")
-	// router.Use(middleware.DeserializeUser())
-	router.POST("", pc.stationController.CreateStation)
 	router.GET("", pc.stationController.FindStations)
-	router.PUT("/:stationId", pc.stationController.UpdateStation)
+	router.GET("/nearby", pc.stationController.FindNearbyStations)
 	router.GET("/:stationId", pc.stationController.FindStationById)
-	router.DELETE("/:stationId", pc.stationController.DeleteStation)
+
+	staff := router.Group("", middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin, models.RoleOperator))
+	staff.POST("", pc.stationController.CreateStation)
+	staff.PUT("/:stationId", pc.stationController.UpdateStation)
+	staff.DELETE("/:stationId", pc.stationController.DeleteStation)
+	staff.POST("/:stationId/photos", pc.stationController.AddStationPhotos)
+	staff.PUT("/:stationId/photos/order", pc.stationController.ReorderStationPhotos)
+	staff.PUT("/:stationId/photos/:photoId/cover", pc.stationController.SetStationCover)
+	staff.DELETE("/:stationId/photos/:photoId", pc.stationController.DeleteStationPhoto)
+
+	admin := router.Group("", middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin))
+	admin.POST("/import", pc.stationController.ImportStations)
+	admin.GET("/:stationId/closures", pc.stationController.FindStationClosures)
+	admin.POST("/:stationId/closures", pc.stationController.CreateStationClosure)
+	admin.DELETE("/:stationId/closures/:closureId", pc.stationController.DeleteStationClosure)
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/mliem2k/ottb-go/controllers"
 	"github.com/mliem2k/ottb-go/middleware"
+	"github.com/mliem2k/ottb-go/models"
 )
 
 type UserRouteController struct {
@@ -18,4 +19,7 @@ func (uc *UserRouteController) UserRoute(rg *gin.RouterGroup) {
 
 	router := rg.Group("users")
 	router.GET("/me", middleware.DeserializeUser(), uc.userController.GetMe)
+	router.PATCH("/me/preferences", middleware.DeserializeUser(), uc.userController.UpdatePreferences)
+	router.PATCH("/:userId/role", middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin), uc.userController.UpdateUserRole)
+	router.POST("/:userId/unlock", middleware.DeserializeUser(), middleware.RequireRole(models.RoleAdmin), uc.userController.UnlockUser)
 }
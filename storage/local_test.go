@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// failingReader returns some data and then an error, like an upload cut off
+// half way.
+type failingReader struct{ read bool }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, errors.New("connection reset")
+	}
+	r.read = true
+	return copy(p, "partial"), nil
+}
+
+func TestLocalPut(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir, "http://localhost:8000/uploads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	data := []byte("complete")
+	if err := store.Put(ctx, "stations/a.jpg", bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := store.Get(ctx, "stations/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("got %q, %v; want %q", got, err, data)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "stations", "a.jpg")); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("got %v, %v; want a file readable by the web server", info, err)
+	}
+
+	// A failed write leaves the previous file in place and no temporary file
+	if err := store.Put(ctx, "stations/a.jpg", &failingReader{}, 100, "image/jpeg"); err == nil {
+		t.Error("a failed upload was reported as stored")
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "stations", "a.jpg")); !bytes.Equal(got, data) {
+		t.Errorf("got %q after a failed write, want %q", got, data)
+	}
+	if err := store.Put(ctx, "b.jpg", &failingReader{}, 100, "image/jpeg"); err == nil {
+		t.Error("a failed upload was reported as stored")
+	}
+	if _, err := store.Get(ctx, "b.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a failed write, want ErrNotFound", err)
+	}
+	for _, sub := range []string{"", "stations"} {
+		entries, _ := os.ReadDir(filepath.Join(dir, sub))
+		for _, entry := range entries {
+			if !entry.IsDir() && entry.Name() != "a.jpg" {
+				t.Errorf("left %s behind in %q", entry.Name(), sub)
+			}
+		}
+	}
+
+	if got, want := store.URL("stations/a.jpg"), "http://localhost:8000/uploads/stations/a.jpg"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLocalDelete(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "a.jpg", bytes.NewReader([]byte("data")), 4, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Delete(ctx, "a.jpg"); err != nil {
+			t.Errorf("delete %d: %v", i+1, err)
+		}
+	}
+	if _, err := store.Get(ctx, "a.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalKeyEscape(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(filepath.Join(root, "uploads"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	outside := filepath.Join(root, "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "/", "../secret.txt", "stations/../../secret.txt", "..", "a/.."} {
+		if err := store.Put(ctx, key, bytes.NewReader([]byte("x")), 1, "text/plain"); err == nil {
+			t.Errorf("%q: put accepted", key)
+		}
+		if reader, err := store.Get(ctx, key); err == nil {
+			reader.Close()
+			t.Errorf("%q: get accepted", key)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("%q: delete accepted", key)
+		}
+	}
+
+	if got, err := os.ReadFile(outside); err != nil || string(got) != "secret" {
+		t.Errorf("got %q, %v; want the file outside the store untouched", got, err)
+	}
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// fakeS3 is a path-style bucket that checks every request's signature.
+type fakeS3 struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f.verify(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	prefix := "/" + f.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(body)) != r.ContentLength {
+			http.Error(w, "short body", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = body
+	case http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		if _, ok := f.objects[key]; !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verify recomputes the Signature Version 4 signature of r from what arrived
+// on the wire.
+func (f *fakeS3) verify(r *http.Request) error {
+	var credential, signedHeaders, signature string
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return errors.New("missing AWS4-HMAC-SHA256 authorization")
+	}
+	for _, field := range strings.Split(auth, ", ") {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			signature = value
+		}
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") {
+		return errors.New("missing X-Amz-Date")
+	}
+	scope := amzDate[:8] + "/us-east-1/s3/aws4_request"
+	if credential != testAccessKey+"/"+scope {
+		return errors.New("got credential " + credential)
+	}
+	if signedHeaders != "host;x-amz-content-sha256;x-amz-date" {
+		return errors.New("got signed headers " + signedHeaders)
+	}
+
+	canonicalRequest := r.Method + "\n" +
+		r.URL.EscapedPath() + "\n" +
+		r.URL.RawQuery + "\n" +
+		"host:" + r.Host + "\n" +
+		"x-amz-content-sha256:" + r.Header.Get("X-Amz-Content-Sha256") + "\n" +
+		"x-amz-date:" + amzDate + "\n\n" +
+		signedHeaders + "\n" +
+		r.Header.Get("X-Amz-Content-Sha256")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{amzDate[:8], "us-east-1", "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	want := hmacSHA256(key, stringToSign)
+	if got, err := hex.DecodeString(signature); err != nil || !hmac.Equal(got, want) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func newTestS3(t *testing.T, secretKey string) (*S3Store, *fakeS3) {
+	fake := &fakeS3{bucket: "photos", objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "photos",
+		AccessKey: testAccessKey,
+		SecretKey: secretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, fake
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	store, fake := newTestS3(t, testSecretKey)
+	ctx := context.Background()
+	data := []byte("\xff\xd8 not quite a JPEG")
+
+	for _, key := range []string{"1712345678.jpg", "stations/a b+c.png"} {
+		if err := store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		fake.mu.Lock()
+		_, ok := fake.objects[key]
+		fake.mu.Unlock()
+		if !ok {
+			t.Errorf("%s: not stored under its key", key)
+		}
+
+		reader, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		got, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%s: got %q, %v; want %q", key, got, err, data)
+		}
+
+		if err := store.Delete(ctx, key); err != nil {
+			t.Errorf("%s: %v", key, err)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: got %v after delete, want ErrNotFound", key, err)
+		}
+	}
+}
+
+func TestS3Missing(t *testing.T) {
+	store, _ := newTestS3(t, testSecretKey)
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "missing.jpg"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestS3WrongSecret(t *testing.T) {
+	store, _ := newTestS3(t, "not the secret")
+	err := store.Put(context.Background(), "a.jpg", strings.NewReader("data"), 4, "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("got %v, want the request refused", err)
+	}
+}
+
+func TestS3URL(t *testing.T) {
+	for _, test := range []struct {
+		config S3Config
+		want   string
+	}{
+		{
+			S3Config{Endpoint: "http://localhost:9000", Bucket: "photos", PathStyle: true},
+			"http://localhost:9000/photos/stations/a%20b.png",
+		},
+		{
+			S3Config{Endpoint: "https://s3.eu-central-1.amazonaws.com", Bucket: "photos"},
+			"https://photos.s3.eu-central-1.amazonaws.com/stations/a%20b.png",
+		},
+		{
+			S3Config{Endpoint: "http://localhost:9000", Bucket: "photos", PublicURL: "https://cdn.example.com/"},
+			"https://cdn.example.com/stations/a%20b.png",
+		},
+	} {
+		store, err := NewS3Store(test.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := store.URL("stations/a b.png"); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// Metadata holds the EXIF fields we keep about a photo.
+type Metadata struct {
+	TakenAt     *time.Time
+	CameraMake  string
+	CameraModel string
+	// Orientation is the EXIF orientation (1-8); 0 when absent.
+	Orientation int
+	Latitude    *float64
+	Longitude   *float64
+}
+
+var errNoExif = errors.New("no EXIF data")
+
+const (
+	tagMake                = 0x010F
+	tagModel               = 0x0110
+	tagOrientation         = 0x0112
+	tagDateTime            = 0x0132
+	tagExifIFD             = 0x8769
+	tagGPSIFD              = 0x8825
+	tagDateTimeOriginal    = 0x9003
+	tagOffsetTimeOriginal  = 0x9011
+	tagGPSLatitudeRef      = 0x0001
+	tagGPSLatitude         = 0x0002
+	tagGPSLongitudeRef     = 0x0003
+	tagGPSLongitude        = 0x0004
+	exifDateTimeLayout     = "2006:01:02 15:04:05"
+	exifDateTimeZoneLayout = "2006:01:02 15:04:05-07:00"
+)
+
+// ReadMetadata extracts EXIF metadata from an image of the given MIME type.
+func ReadMetadata(data []byte, mimeType string) (Metadata, error) {
+	var tiff []byte
+	switch mimeType {
+	case "image/jpeg":
+		tiff = jpegExif(data)
+	case "image/png":
+		tiff = pngExif(data)
+	case "image/webp":
+		tiff = webpExif(data)
+	case "image/heic":
+		tiff = heicExif(data)
+	}
+	if tiff == nil {
+		return Metadata{}, errNoExif
+	}
+	return parseTIFF(tiff)
+}
+
+// jpegExif returns the TIFF payload of the first Exif APP1 segment.
+func jpegExif(data []byte) []byte {
+	var exif []byte
+	walkJPEG(data, func(marker byte, segment []byte) {
+		if exif == nil && marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			exif = segment[6:]
+		}
+	})
+	return exif
+}
+
+func pngExif(data []byte) []byte {
+	var exif []byte
+	walkPNG(data, func(chunkType string, chunk []byte) {
+		if exif == nil && chunkType == "eXIf" {
+			exif = chunk
+		}
+	})
+	return exif
+}
+
+func webpExif(data []byte) []byte {
+	var exif []byte
+	walkRIFF(data, func(chunkType string, chunk []byte) {
+		if exif == nil && chunkType == "EXIF" {
+			exif = bytes.TrimPrefix(chunk, []byte("Exif\x00\x00"))
+		}
+	})
+	return exif
+}
+
+func heicExif(data []byte) []byte {
+	for _, extent := range heifItemExtents(data, "Exif") {
+		item := data[extent[0] : extent[0]+extent[1]]
+		// The item starts with the offset of the TIFF header after these four bytes.
+		if len(item) < 4 {
+			continue
+		}
+		start := 4 + uint64(binary.BigEndian.Uint32(item))
+		if start < uint64(len(item)) {
+			return item[start:]
+		}
+	}
+	return nil
+}
+
+// tiffReader reads IFD entries out of a TIFF structure.
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	tag, kind uint16
+	count     uint32
+	value     []byte
+}
+
+func parseTIFF(data []byte) (Metadata, error) {
+	if len(data) < 8 {
+		return Metadata{}, errNoExif
+	}
+	r := tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return Metadata{}, errNoExif
+	}
+	if r.order.Uint16(data[2:4]) != 42 {
+		return Metadata{}, errNoExif
+	}
+
+	ifd0 := r.readIFD(r.order.Uint32(data[4:8]))
+
+	var meta Metadata
+	if entry, ok := ifd0[tagMake]; ok {
+		meta.CameraMake = r.ascii(entry)
+	}
+	if entry, ok := ifd0[tagModel]; ok {
+		meta.CameraModel = r.ascii(entry)
+	}
+	if entry, ok := ifd0[tagOrientation]; ok {
+		if orientation := int(r.uint(entry, 0)); orientation >= 1 && orientation <= 8 {
+			meta.Orientation = orientation
+		}
+	}
+
+	taken, offset := "", ""
+	if entry, ok := ifd0[tagDateTime]; ok {
+		taken = r.ascii(entry)
+	}
+	if entry, ok := ifd0[tagExifIFD]; ok {
+		exifIFD := r.readIFD(r.uint(entry, 0))
+		if entry, ok := exifIFD[tagDateTimeOriginal]; ok {
+			taken = r.ascii(entry)
+		}
+		if entry, ok := exifIFD[tagOffsetTimeOriginal]; ok {
+			offset = r.ascii(entry)
+		}
+	}
+	if taken != "" {
+		var t time.Time
+		var err error
+		if offset != "" {
+			t, err = time.Parse(exifDateTimeZoneLayout, taken+offset)
+		} else {
+			t, err = time.ParseInLocation(exifDateTimeLayout, taken, time.UTC)
+		}
+		if err == nil {
+			meta.TakenAt = &t
+		}
+	}
+
+	if entry, ok := ifd0[tagGPSIFD]; ok {
+		gps := r.readIFD(r.uint(entry, 0))
+		meta.Latitude = r.coordinate(gps, tagGPSLatitude, tagGPSLatitudeRef, "S", 90)
+		meta.Longitude = r.coordinate(gps, tagGPSLongitude, tagGPSLongitudeRef, "W", 180)
+	}
+
+	return meta, nil
+}
+
+var tiffTypeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+func (r *tiffReader) readIFD(offset uint32) map[uint16]ifdEntry {
+	entries := make(map[uint16]ifdEntry)
+	if offset == 0 || uint64(offset)+2 > uint64(len(r.data)) {
+		return entries
+	}
+
+	count := int(r.order.Uint16(r.data[offset:]))
+	pos := offset + 2
+	for i := 0; i < count; i++ {
+		if uint64(pos)+12 > uint64(len(r.data)) {
+			break
+		}
+		raw := r.data[pos : pos+12]
+		entry := ifdEntry{
+			tag:   r.order.Uint16(raw[0:2]),
+			kind:  r.order.Uint16(raw[2:4]),
+			count: r.order.Uint32(raw[4:8]),
+		}
+		pos += 12
+
+		size, ok := tiffTypeSizes[entry.kind]
+		if !ok || entry.count > uint32(len(r.data)) {
+			continue
+		}
+		length := uint64(size) * uint64(entry.count)
+		if length <= 4 {
+			entry.value = raw[8 : 8+length]
+		} else {
+			valueOffset := uint64(r.order.Uint32(raw[8:12]))
+			if valueOffset+length > uint64(len(r.data)) {
+				continue
+			}
+			entry.value = r.data[valueOffset : valueOffset+length]
+		}
+		entries[entry.tag] = entry
+	}
+	return entries
+}
+
+func (r *tiffReader) ascii(entry ifdEntry) string {
+	return strings.TrimSpace(strings.TrimRight(string(entry.value), "\x00"))
+}
+
+// uint reads the i-th SHORT or LONG value of entry.
+func (r *tiffReader) uint(entry ifdEntry, i int) uint32 {
+	switch entry.kind {
+	case 3:
+		if len(entry.value) >= 2*(i+1) {
+			return uint32(r.order.Uint16(entry.value[2*i:]))
+		}
+	case 4:
+		if len(entry.value) >= 4*(i+1) {
+			return r.order.Uint32(entry.value[4*i:])
+		}
+	}
+	return 0
+}
+
+func (r *tiffReader) rational(entry ifdEntry, i int) float64 {
+	if entry.kind != 5 || len(entry.value) < 8*(i+1) {
+		return math.NaN()
+	}
+	num := r.order.Uint32(entry.value[8*i:])
+	den := r.order.Uint32(entry.value[8*i+4:])
+	if den == 0 {
+		return math.NaN()
+	}
+	return float64(num) / float64(den)
+}
+
+// coordinate converts a GPS degrees/minutes/seconds triple into signed
+// decimal degrees.
+func (r *tiffReader) coordinate(gps map[uint16]ifdEntry, valueTag, refTag uint16, negativeRef string, limit float64) *float64 {
+	entry, ok := gps[valueTag]
+	if !ok || entry.count < 3 {
+		return nil
+	}
+	degrees := r.rational(entry, 0) + r.rational(entry, 1)/60 + r.rational(entry, 2)/3600
+	if math.IsNaN(degrees) || degrees > limit {
+		return nil
+	}
+	if ref, ok := gps[refTag]; ok && r.ascii(ref) == negativeRef {
+		degrees = -degrees
+	}
+	return &degrees
+}
@@ -0,0 +1,32 @@
+package upload
+
+import (
+	"testing"
+)
+
+func TestParseTIFF(t *testing.T) {
+	meta, err := parseTIFF(orientationExif(8))
+	if err != nil || meta.Orientation != 8 {
+		t.Fatalf("got orientation %d, %v; want 8", meta.Orientation, err)
+	}
+
+	// An IFD offset past the end of the data
+	tiff := orientationExif(8)
+	copy(tiff[4:8], []byte{0xFF, 0xFF, 0xFF, 0xFF})
+	if meta, _ := parseTIFF(tiff); meta.Orientation != 0 {
+		t.Errorf("got orientation %d from an IFD outside the data", meta.Orientation)
+	}
+}
+
+func FuzzReadMetadata(f *testing.F) {
+	for _, seed := range seedImages(f) {
+		f.Add(seed)
+	}
+	f.Add(orientationExif(3))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if mimeType, _, ok := Sniff(data); ok {
+			ReadMetadata(data, mimeType)
+		}
+		parseTIFF(data)
+	})
+}
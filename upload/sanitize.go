@@ -0,0 +1,484 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// Sanitize returns a copy of data with identifying metadata (EXIF, XMP, IPTC,
+// comments) removed. JPEG and PNG images whose EXIF orientation is not the
+// default are re-encoded upright, JPEGs keeping their ICC profile; otherwise
+// metadata is cut out without touching the pixel data. WebP cannot be re-encoded here, so its EXIF is
+// replaced by one holding only the orientation; HEIC keeps its rotation in
+// item properties that are left alone. Images above maxPixels are not
+// decoded but rejected with ErrInvalidImage.
+func Sanitize(data []byte, mimeType string, orientation int, maxPixels int64) ([]byte, error) {
+	switch mimeType {
+	case "image/jpeg":
+		if orientation > 1 {
+			upright, err := reencode(data, orientation, maxPixels, jpegEncoder)
+			if err != nil {
+				return nil, err
+			}
+			return withICCProfile(upright, data), nil
+		}
+		return stripJPEG(data), nil
+	case "image/png":
+		if orientation > 1 {
+			return reencode(data, orientation, maxPixels, pngEncoder)
+		}
+		return stripPNG(data), nil
+	case "image/webp":
+		return stripWebP(data, orientation), nil
+	case "image/heic":
+		return stripHEIC(data), nil
+	}
+	return nil, ErrUnsupportedType
+}
+
+func jpegEncoder(buf *bytes.Buffer, img image.Image) error {
+	return jpeg.Encode(buf, img, &jpeg.Options{Quality: 92})
+}
+
+func pngEncoder(buf *bytes.Buffer, img image.Image) error {
+	return png.Encode(buf, img)
+}
+
+func reencode(data []byte, orientation int, maxPixels int64, encode func(*bytes.Buffer, image.Image) error) ([]byte, error) {
+	img, _, err := decodeImage(data, maxPixels)
+	if errors.Is(err, errTooManyPixels) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, orient(img, orientation)); err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// orient applies an EXIF orientation so the result displays upright.
+func orient(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for dy := 0; dy < dh; dy++ {
+		for dx := 0; dx < dw; dx++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-dx, dy
+			case 3: // rotated 180°
+				sx, sy = w-1-dx, h-1-dy
+			case 4: // mirrored vertically
+				sx, sy = dx, h-1-dy
+			case 5: // transposed
+				sx, sy = dy, dx
+			case 6: // needs 90° clockwise
+				sx, sy = dy, h-1-dx
+			case 7: // transversed
+				sx, sy = w-1-dy, h-1-dx
+			case 8: // needs 90° counter-clockwise
+				sx, sy = w-1-dy, dx
+			default:
+				sx, sy = dx, dy
+			}
+			si := src.PixOffset(sx, sy)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+type jpegSegment struct {
+	marker     byte
+	start, end int
+	payload    []byte
+}
+
+// jpegSegments lists the marker segments before the first scan and returns
+// the offset at which the scan starts.
+func jpegSegments(data []byte) ([]jpegSegment, int) {
+	var segments []jpegSegment
+	pos := 2 // skip SOI
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			break
+		}
+		marker := data[pos+1]
+		if marker == 0xFF { // fill byte
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // start of scan, end of image
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segments = append(segments, jpegSegment{marker, pos, pos + 2 + length, data[pos+4 : pos+2+length]})
+		pos += 2 + length
+	}
+	return segments, pos
+}
+
+func walkJPEG(data []byte, fn func(marker byte, segment []byte)) {
+	segments, _ := jpegSegments(data)
+	for _, segment := range segments {
+		fn(segment.marker, segment.payload)
+	}
+}
+
+// stripJPEG drops APP1 (EXIF, XMP), APP13 (IPTC), comments and vendor APPn
+// segments. JFIF (APP0), ICC profiles (APP2) and Adobe (APP14) segments are
+// kept because they affect how the image is decoded.
+func stripJPEG(data []byte) []byte {
+	segments, scan := jpegSegments(data)
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	for _, segment := range segments {
+		switch {
+		case segment.marker == 0xE0, segment.marker == 0xE2, segment.marker == 0xEE:
+		case segment.marker >= 0xE1 && segment.marker <= 0xEF, segment.marker == 0xFE:
+			continue
+		}
+		out = append(out, data[segment.start:segment.end]...)
+	}
+	return append(out, data[scan:]...)
+}
+
+var iccProfileID = []byte("ICC_PROFILE\x00")
+
+// withICCProfile copies the ICC profile of original into upright, a JPEG
+// re-encoded from it, right after the SOI marker. The encoder always writes
+// YCbCr, so only RGB profiles are carried over; a CMYK or grayscale profile
+// would no longer describe the pixels.
+func withICCProfile(upright, original []byte) []byte {
+	var profile [][]byte
+	rgb := false
+	segments, _ := jpegSegments(original)
+	for _, segment := range segments {
+		if segment.marker != 0xE2 || !bytes.HasPrefix(segment.payload, iccProfileID) {
+			continue
+		}
+		profile = append(profile, original[segment.start:segment.end])
+		// The profile header, with its colour space at offset 16, is in the
+		// first chunk after the identifier and the chunk numbers
+		chunk := segment.payload[len(iccProfileID):]
+		if len(chunk) >= 2+20 && chunk[0] == 1 {
+			rgb = string(chunk[2+16:2+20]) == "RGB "
+		}
+	}
+	if !rgb || len(upright) < 2 {
+		return upright
+	}
+
+	out := append([]byte(nil), upright[:2]...)
+	for _, segment := range profile {
+		out = append(out, segment...)
+	}
+	return append(out, upright[2:]...)
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func walkPNG(data []byte, fn func(chunkType string, chunk []byte)) {
+	pngChunks(data, func(chunkType string, chunk []byte, raw []byte) {
+		fn(chunkType, chunk)
+	})
+}
+
+func pngChunks(data []byte, fn func(chunkType string, chunk []byte, raw []byte)) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return
+	}
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return
+		}
+		fn(string(data[pos+4:pos+8]), data[pos+8:pos+8+length], data[pos:end])
+		pos = end
+	}
+}
+
+// stripPNG drops the EXIF, text and timestamp chunks.
+func stripPNG(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	pngChunks(data, func(chunkType string, chunk []byte, raw []byte) {
+		switch chunkType {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+			return
+		}
+		out = append(out, raw...)
+	})
+	return out
+}
+
+func walkRIFF(data []byte, fn func(chunkType string, chunk []byte)) {
+	riffChunks(data, func(chunkType string, chunk []byte, raw []byte) {
+		fn(chunkType, chunk)
+	})
+}
+
+func riffChunks(data []byte, fn func(chunkType string, chunk []byte, raw []byte)) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" {
+		return
+	}
+	pos := 12
+	for pos+8 <= len(data) {
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + length + length%2 // chunks are padded to even sizes
+		if length < 0 || pos+8+length > len(data) {
+			return
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		fn(string(data[pos:pos+4]), data[pos+8:pos+8+length], data[pos:end])
+		pos = end
+	}
+}
+
+// stripWebP drops the XMP chunk and clears its flag in the extended header.
+// The EXIF chunk is dropped as well unless the image is rotated, in which
+// case it is replaced by one holding only the orientation.
+func stripWebP(data []byte, orientation int) []byte {
+	flags := byte(0x04) // XMP present
+	if orientation <= 1 {
+		flags |= 0x08 // EXIF present
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	riffChunks(data, func(chunkType string, chunk []byte, raw []byte) {
+		switch chunkType {
+		case "XMP ":
+			return
+		case "EXIF":
+			if orientation > 1 {
+				exif := orientationExif(orientation)
+				out = append(out, "EXIF"...)
+				out = binary.LittleEndian.AppendUint32(out, uint32(len(exif)))
+				out = append(out, exif...)
+			}
+			return
+		case "VP8X":
+			start := len(out)
+			out = append(out, raw...)
+			if len(chunk) > 0 {
+				out[start+8] &^= flags
+			}
+			return
+		}
+		out = append(out, raw...)
+	})
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out
+}
+
+// orientationExif is a big-endian TIFF structure whose only entry is the
+// orientation. It has an even length, so it needs no RIFF padding.
+func orientationExif(orientation int) []byte {
+	return []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // header, IFD0 at offset 8
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, byte(orientation), 0x00, 0x00, // orientation, SHORT
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+}
+
+// stripHEIC blanks out the EXIF and XMP items of a HEIF file. The items stay
+// in place so no offsets in the file have to be rewritten.
+func stripHEIC(data []byte) []byte {
+	out := append([]byte(nil), data...)
+	for _, itemType := range []string{"Exif", "mime"} {
+		for _, extent := range heifItemExtents(data, itemType) {
+			for i := extent[0]; i < extent[0]+extent[1]; i++ {
+				out[i] = 0
+			}
+		}
+	}
+	return out
+}
+
+// heifBoxes calls fn for each ISO base media box in data.
+func heifBoxes(data []byte, fn func(boxType string, payload []byte)) {
+	pos := 0
+	for pos+8 <= len(data) {
+		size := uint64(binary.BigEndian.Uint32(data[pos:]))
+		boxType := string(data[pos+4 : pos+8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data) - pos)
+		case 1:
+			if pos+16 > len(data) {
+				return
+			}
+			size = binary.BigEndian.Uint64(data[pos+8:])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)-pos) {
+			return
+		}
+		fn(boxType, data[pos+int(header):pos+int(size)])
+		pos += int(size)
+	}
+}
+
+// heifItemExtents returns the file offset and length of every extent of the
+// items of itemType that are stored directly in the file.
+func heifItemExtents(data []byte, itemType string) [][2]int {
+	var iinf, iloc []byte
+	heifBoxes(data, func(boxType string, payload []byte) {
+		if boxType != "meta" || len(payload) < 4 {
+			return
+		}
+		heifBoxes(payload[4:], func(childType string, child []byte) {
+			switch childType {
+			case "iinf":
+				iinf = child
+			case "iloc":
+				iloc = child
+			}
+		})
+	})
+	if iinf == nil || iloc == nil {
+		return nil
+	}
+
+	wanted := make(map[uint32]bool)
+	if len(iinf) < 6 {
+		return nil
+	}
+	entries := iinf[6:]
+	if iinf[0] != 0 {
+		if len(iinf) < 8 {
+			return nil
+		}
+		entries = iinf[8:]
+	}
+	heifBoxes(entries, func(boxType string, infe []byte) {
+		if boxType != "infe" || len(infe) < 4 || infe[0] < 2 {
+			return
+		}
+		var id uint32
+		rest := infe[4:]
+		if infe[0] == 2 {
+			if len(rest) < 8 {
+				return
+			}
+			id, rest = uint32(binary.BigEndian.Uint16(rest)), rest[2:]
+		} else {
+			if len(rest) < 10 {
+				return
+			}
+			id, rest = binary.BigEndian.Uint32(rest), rest[4:]
+		}
+		if string(rest[2:6]) == itemType {
+			wanted[id] = true
+		}
+	})
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	r := &heifReader{data: iloc}
+	version := r.uint(1)
+	r.skip(3)
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), 0
+	if version >= 1 {
+		indexSize = int(sizes & 0x0F)
+	}
+	itemCount := r.uint(2)
+	if version >= 2 {
+		itemCount = r.uint(4)
+	}
+
+	var extents [][2]int
+	for i := uint64(0); i < itemCount && !r.failed; i++ {
+		var id uint64
+		if version < 2 {
+			id = r.uint(2)
+		} else {
+			id = r.uint(4)
+		}
+		constructionMethod := uint64(0)
+		if version >= 1 {
+			constructionMethod = r.uint(2) & 0x0F
+		}
+		r.skip(2) // data reference index
+		baseOffset := r.uint(baseOffsetSize)
+		extentCount := r.uint(2)
+		for j := uint64(0); j < extentCount && !r.failed; j++ {
+			if indexSize > 0 {
+				r.skip(indexSize)
+			}
+			extentOffset := r.uint(offsetSize)
+			length := r.uint(lengthSize)
+			if r.failed || !wanted[uint32(id)] || constructionMethod != 0 || extentOffset > math.MaxUint64-baseOffset {
+				continue
+			}
+			// Both values come from the file, so compare without adding them
+			offset := baseOffset + extentOffset
+			if offset <= uint64(len(data)) && length <= uint64(len(data))-offset {
+				extents = append(extents, [2]int{int(offset), int(length)})
+			}
+		}
+	}
+	return extents
+}
+
+// heifReader reads big-endian integers of varying width, remembering whether
+// it ran past the end of its data.
+type heifReader struct {
+	data   []byte
+	pos    int
+	failed bool
+}
+
+func (r *heifReader) uint(size int) uint64 {
+	if size == 0 {
+		return 0
+	}
+	if r.pos+size > len(r.data) {
+		r.failed = true
+		return 0
+	}
+	var v uint64
+	for _, b := range r.data[r.pos : r.pos+size] {
+		v = v<<8 | uint64(b)
+	}
+	r.pos += size
+	return v
+}
+
+func (r *heifReader) skip(n int) {
+	r.uint(n)
+}
@@ -0,0 +1,260 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// box builds an ISO base media box.
+func box(boxType string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	out = append(out, boxType...)
+	return append(out, body...)
+}
+
+// heicWithExif builds a HEIF file whose only item is an Exif item with one
+// extent at offset and length; when payload is given the extent covers it
+// instead.
+func heicWithExif(offset, length uint64, payload []byte) []byte {
+	infe := box("infe", []byte{2, 0, 0, 0}, []byte{0, 1, 0, 0}, []byte("Exif\x00"))
+	iinf := box("iinf", []byte{0, 0, 0, 0}, []byte{0, 1}, infe)
+	iloc := func(offset uint64) []byte {
+		return box("iloc",
+			[]byte{0, 0, 0, 0},
+			[]byte{0x88, 0x00}, // 8-byte offsets and lengths, no base offset
+			[]byte{0, 1},       // item count
+			[]byte{0, 1, 0, 0}, // item 1, data reference 0
+			[]byte{0, 1},       // extent count
+			binary.BigEndian.AppendUint64(nil, offset),
+			binary.BigEndian.AppendUint64(nil, length),
+		)
+	}
+	meta := func(offset uint64) []byte {
+		return box("meta", []byte{0, 0, 0, 0}, iinf, iloc(offset))
+	}
+	ftyp := box("ftyp", []byte("heic"), []byte{0, 0, 0, 0})
+	if payload != nil {
+		// The meta box has the same size whatever the extent, so the
+		// payload starts right after it
+		offset, length = uint64(len(ftyp)+len(meta(0))), uint64(len(payload))
+	}
+	return append(append(ftyp, meta(offset)...), payload...)
+}
+
+func testImage(t testing.TB, encode func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func seedImages(t testing.TB) [][]byte {
+	pngData := testImage(t, func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) })
+	jpegData := testImage(t, func(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) })
+
+	// Insert an EXIF block right after the PNG header chunk
+	exif := orientationExif(6)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(exif)))
+	chunk = append(append(chunk, "eXIf"...), exif...)
+	chunk = append(chunk, 0, 0, 0, 0)
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	orientedPNG := append(append(append([]byte(nil), pngData[:ihdrEnd]...), chunk...), pngData[ihdrEnd:]...)
+
+	return [][]byte{
+		pngData,
+		jpegData,
+		orientedPNG,
+		heicWithExif(0, 0, append([]byte{0, 0, 0, 0}, exif...)),
+		heicWithExif(0xFFFFFFFFFFFFFFFF, 2, nil),
+		[]byte("RIFF\x1a\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00EXIF"),
+	}
+}
+
+func TestHEICExtentOverflow(t *testing.T) {
+	for _, length := range []uint64{2, 0xFFFFFFFFFFFFFFFF} {
+		data := heicWithExif(0xFFFFFFFFFFFFFFFF, length, nil)
+		if extents := heifItemExtents(data, "Exif"); len(extents) != 0 {
+			t.Errorf("length %d: got extents %v outside the file", length, extents)
+		}
+		if _, err := ReadMetadata(data, "image/heic"); err == nil {
+			t.Errorf("length %d: read metadata from an extent outside the file", length)
+		}
+		if _, err := Sanitize(data, "image/heic", 0, 0); err != nil {
+			t.Errorf("length %d: %v", length, err)
+		}
+	}
+}
+
+func TestHEIFLargeBoxSize(t *testing.T) {
+	data := box("ftyp", []byte("heic"), []byte{0, 0, 0, 0})
+	// A 64-bit size that wraps around when added to the box's position
+	data = append(data, 0, 0, 0, 1, 'm', 'e', 't', 'a')
+	data = binary.BigEndian.AppendUint64(data, 0xFFFFFFFFFFFFFFF8)
+	data = append(data, make([]byte, 16)...)
+
+	var boxes []string
+	heifBoxes(data, func(boxType string, payload []byte) {
+		boxes = append(boxes, boxType)
+	})
+	if len(boxes) != 1 || boxes[0] != "ftyp" {
+		t.Errorf("got boxes %v, want only ftyp", boxes)
+	}
+}
+
+func TestSanitizeHEIC(t *testing.T) {
+	data := heicWithExif(0, 0, append([]byte{0, 0, 0, 0}, orientationExif(6)...))
+	meta, err := ReadMetadata(data, "image/heic")
+	if err != nil || meta.Orientation != 6 {
+		t.Fatalf("got orientation %d, %v; want 6", meta.Orientation, err)
+	}
+
+	clean, err := Sanitize(data, "image/heic", meta.Orientation, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clean) != len(data) {
+		t.Errorf("got %d bytes, want %d", len(clean), len(data))
+	}
+	if _, err := ReadMetadata(clean, "image/heic"); err == nil {
+		t.Error("EXIF item survived sanitizing")
+	}
+}
+
+func FuzzSanitize(f *testing.F) {
+	for _, seed := range seedImages(f) {
+		f.Add(seed, 6)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, orientation int) {
+		mimeType, _, ok := Sniff(data)
+		if !ok {
+			return
+		}
+		Sanitize(data, mimeType, orientation%9, 1<<20)
+	})
+}
+
+func TestSanitizeReencode(t *testing.T) {
+	data := pngClaiming(t, 4, 2)
+	upright, err := Sanitize(data, "image/png", 6, 50_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(upright))
+	if err != nil || config.Width != 2 || config.Height != 4 {
+		t.Errorf("got %d×%d, %v; want 2×4", config.Width, config.Height, err)
+	}
+
+	bomb := pngClaiming(t, 50000, 50000)
+	if _, err := Sanitize(bomb, "image/png", 6, 50_000_000); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("got %v, want ErrInvalidImage", err)
+	}
+}
+
+// riffChunk builds a RIFF chunk, padded to an even size.
+func riffChunk(chunkType string, payload []byte) []byte {
+	out := append([]byte(chunkType), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	out = append(out, payload...)
+	if len(payload)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func TestSanitizeWebP(t *testing.T) {
+	exif := append(orientationExif(6), "Canon EOS"...)
+	body := bytes.Join([][]byte{
+		[]byte("WEBP"),
+		riffChunk("VP8X", []byte{0x0C, 0, 0, 0, 3, 0, 0, 1, 0, 0}),
+		riffChunk("VP8L", []byte{0x2F, 0x03, 0x40, 0x00}),
+		riffChunk("EXIF", exif),
+		riffChunk("XMP ", []byte("<x:xmpmeta/>")),
+	}, nil)
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	data = append(data, body...)
+
+	for _, orientation := range []int{1, 6} {
+		clean, err := Sanitize(data, "image/webp", orientation, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(clean, []byte("xmpmeta")) || bytes.Contains(clean, []byte("Canon")) {
+			t.Errorf("orientation %d: metadata survived sanitizing", orientation)
+		}
+		if size := binary.LittleEndian.Uint32(clean[4:8]); int(size) != len(clean)-8 {
+			t.Errorf("orientation %d: RIFF size %d for %d bytes", orientation, size, len(clean))
+		}
+
+		meta, _ := ReadMetadata(clean, "image/webp")
+		flags := clean[20]
+		if orientation == 1 && (meta.Orientation != 0 || flags != 0) {
+			t.Errorf("got orientation %d and flags %#x, want no EXIF", meta.Orientation, flags)
+		}
+		if orientation == 6 && (meta.Orientation != 6 || flags != 0x08) {
+			t.Errorf("got orientation %d and flags %#x, want orientation 6 kept", meta.Orientation, flags)
+		}
+	}
+}
+
+// jpegWithICC inserts APP1 (EXIF) and APP2 (ICC profile) segments after the
+// SOI marker of a JPEG, the profile declaring colourSpace.
+func jpegWithICC(t *testing.T, orientation int, colourSpace string) []byte {
+	data := testImage(t, func(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) })
+	segment := func(marker byte, payload []byte) []byte {
+		out := []byte{0xFF, marker}
+		out = binary.BigEndian.AppendUint16(out, uint16(2+len(payload)))
+		return append(out, payload...)
+	}
+	header := make([]byte, 128)
+	copy(header[16:], colourSpace)
+	icc := append(append([]byte(nil), iccProfileID...), 1, 1)
+	icc = append(icc, header...)
+
+	out := append([]byte(nil), data[:2]...)
+	out = append(out, segment(0xE1, append([]byte("Exif\x00\x00"), orientationExif(orientation)...))...)
+	out = append(out, segment(0xE2, icc)...)
+	return append(out, data[2:]...)
+}
+
+func TestSanitizeReencodeICCProfile(t *testing.T) {
+	for _, test := range []struct {
+		colourSpace string
+		kept        bool
+	}{
+		{"RGB ", true},
+		{"CMYK", false},
+		{"GRAY", false},
+	} {
+		data := jpegWithICC(t, 6, test.colourSpace)
+		clean, err := Sanitize(data, "image/jpeg", 6, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var profiles int
+		walkJPEG(clean, func(marker byte, segment []byte) {
+			if marker == 0xE2 && bytes.HasPrefix(segment, iccProfileID) {
+				profiles++
+			}
+		})
+		if kept := profiles == 1; kept != test.kept {
+			t.Errorf("%s: got %d profiles, want kept %v", test.colourSpace, profiles, test.kept)
+		}
+		if meta, _ := ReadMetadata(clean, "image/jpeg"); meta.Orientation != 0 {
+			t.Errorf("%s: EXIF survived re-encoding", test.colourSpace)
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(clean))
+		if err != nil || config.Width != 2 || config.Height != 4 {
+			t.Errorf("%s: got %d×%d, %v; want 2×4", test.colourSpace, config.Width, config.Height, err)
+		}
+	}
+}
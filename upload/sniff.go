@@ -0,0 +1,25 @@
+package upload
+
+import "bytes"
+
+// heifBrands are the ISO base media file brands used by HEIC/HEIF images.
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true,
+	"heim": true, "heis": true, "mif1": true, "msf1": true,
+}
+
+// Sniff identifies an allowed image format from its magic bytes and returns
+// its MIME type and file extension.
+func Sniff(data []byte) (mimeType string, extension string, ok bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg", ".jpg", true
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png", ".png", true
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp", ".webp", true
+	case len(data) >= 12 && string(data[4:8]) == "ftyp" && heifBrands[string(data[8:12])]:
+		return "image/heic", ".heic", true
+	}
+	return "", "", false
+}
@@ -0,0 +1,197 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mliem2k/ottb-go/storage"
+	"github.com/mliem2k/ottb-go/utils"
+)
+
+var (
+	ErrUnsupportedType = errors.New("only JPEG, PNG, WebP and HEIC images are allowed")
+	ErrFileTooLarge    = errors.New("image file is too large")
+	ErrRequestTooLarge = errors.New("upload request is too large")
+	ErrTooManyFiles    = errors.New("too many files in one upload")
+	ErrInvalidForm     = errors.New("failed to parse form data")
+	ErrInvalidImage    = errors.New("image file is corrupt")
+)
+
+// Limits bounds what a single upload request may contain. Zero disables a limit.
+type Limits struct {
+	MaxFileSize    int64
+	MaxRequestSize int64
+	MaxFiles       int
+	// MaxPixels caps width × height of images that get decoded, since a small
+	// file can declare enormous dimensions.
+	MaxPixels int64
+}
+
+// Service validates uploaded images and writes them to a Store under random
+// names. The client-supplied filename and content type are never trusted.
+type Service struct {
+	Store  storage.Store
+	Limits Limits
+}
+
+func NewService(store storage.Store, limits Limits) *Service {
+	return &Service{Store: store, Limits: limits}
+}
+
+// File is an image that has been stored.
+type File struct {
+	Key      string
+	Size     int64
+	MimeType string
+	Width    int
+	Height   int
+	Checksum string
+	// Metadata is what was read from the original EXIF data before it was
+	// stripped from the stored file.
+	Metadata Metadata
+
+	// data holds the contents while the request that stored the file is
+	// still being handled, so variants don't have to read it back.
+	data []byte
+}
+
+// ParseMultipartForm parses r's multipart body while enforcing the request
+// size limit.
+func (s *Service) ParseMultipartForm(w http.ResponseWriter, r *http.Request) error {
+	if s.Limits.MaxRequestSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.Limits.MaxRequestSize)
+	}
+
+	err := r.ParseMultipartForm(10 << 20) // keep up to 10 MB in memory
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return ErrRequestTooLarge
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidForm, err)
+	}
+	return nil
+}
+
+// SaveAll stores every file under prefix. Either all files are stored or,
+// on error, none are left behind.
+func (s *Service) SaveAll(ctx context.Context, prefix string, headers []*multipart.FileHeader) ([]File, error) {
+	if s.Limits.MaxFiles > 0 && len(headers) > s.Limits.MaxFiles {
+		return nil, ErrTooManyFiles
+	}
+
+	var total int64
+	for _, header := range headers {
+		total += header.Size
+	}
+	if s.Limits.MaxRequestSize > 0 && total > s.Limits.MaxRequestSize {
+		return nil, ErrRequestTooLarge
+	}
+
+	files := make([]File, 0, len(headers))
+	for _, header := range headers {
+		file, err := s.Save(ctx, prefix, header)
+		if err != nil {
+			s.DeleteAll(files)
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Save validates a single uploaded file and stores it under prefix with a
+// random name and an extension matching its actual content. EXIF metadata is
+// read into the returned File and stripped from what gets stored.
+func (s *Service) Save(ctx context.Context, prefix string, header *multipart.FileHeader) (File, error) {
+	if s.Limits.MaxFileSize > 0 && header.Size > s.Limits.MaxFileSize {
+		return File{}, ErrFileTooLarge
+	}
+
+	data, err := s.read(header)
+	if err != nil {
+		return File{}, err
+	}
+
+	mimeType, extension, ok := Sniff(data)
+	if !ok {
+		return File{}, ErrUnsupportedType
+	}
+
+	metadata, _ := ReadMetadata(data, mimeType)
+	data, err = Sanitize(data, mimeType, metadata.Orientation, s.Limits.MaxPixels)
+	if err != nil {
+		return File{}, err
+	}
+
+	info := utils.InspectImage(data)
+	file := File{
+		Key:      prefix + uuid.NewString() + extension,
+		Size:     info.Size,
+		MimeType: mimeType,
+		Width:    info.Width,
+		Height:   info.Height,
+		Checksum: info.Checksum,
+		Metadata: metadata,
+		data:     data,
+	}
+	// WebP keeps its pixels as taken and the orientation in EXIF, so its
+	// upright size has the axes swapped when it is turned a quarter
+	if mimeType == "image/webp" && metadata.Orientation >= 5 {
+		file.Width, file.Height = file.Height, file.Width
+	}
+
+	if err := s.Store.Put(ctx, file.Key, bytes.NewReader(data), file.Size, file.MimeType); err != nil {
+		return File{}, fmt.Errorf("save upload: %w", err)
+	}
+	return file, nil
+}
+
+// URL returns the public address of a stored file.
+func (s *Service) URL(key string) string {
+	return s.Store.URL(key)
+}
+
+// Delete removes a stored file, logging rather than failing when it cannot.
+func (s *Service) Delete(key string) {
+	if err := s.Store.Delete(context.Background(), key); err != nil {
+		log.Println("Failed to remove upload:", err)
+	}
+}
+
+// DeleteAll removes stored files, e.g. after a later step of a request failed.
+func (s *Service) DeleteAll(files []File) {
+	for _, file := range files {
+		s.Delete(file.Key)
+	}
+}
+
+// read loads the whole file, refusing to go past the per-file limit even if
+// the multipart header under-reported its size.
+func (s *Service) read(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open upload: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if s.Limits.MaxFileSize > 0 {
+		reader = io.LimitReader(file, s.Limits.MaxFileSize+1)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read upload: %w", err)
+	}
+	if s.Limits.MaxFileSize > 0 && int64(len(data)) > s.Limits.MaxFileSize {
+		return nil, ErrFileTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,157 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// VariantSpec describes a resized copy of an image whose longest edge is at
+// most MaxEdge pixels.
+type VariantSpec struct {
+	Name    string
+	MaxEdge int
+}
+
+// PostVariants are the sizes generated for every post photo.
+var PostVariants = []VariantSpec{
+	{Name: "thumbnail", MaxEdge: 200},
+	{Name: "medium", MaxEdge: 800},
+	{Name: "large", MaxEdge: 1600},
+}
+
+// Variant is a resized copy of a stored file.
+type Variant struct {
+	Name     string
+	Key      string
+	Size     int64
+	MimeType string
+	Width    int
+	Height   int
+}
+
+// SaveVariants stores a resized copy of file for every spec that is smaller
+// than the original. Formats the server cannot decode, such as HEIC, get no
+// variants, and images above Limits.MaxPixels are rejected with
+// ErrInvalidImage. Either all variants are stored or, on error, none are.
+func (s *Service) SaveVariants(ctx context.Context, file File, specs []VariantSpec) ([]Variant, error) {
+	data := file.data
+	if data == nil {
+		reader, err := s.Store.Get(ctx, file.Key)
+		if err != nil {
+			return nil, fmt.Errorf("load original: %w", err)
+		}
+		data, err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("load original: %w", err)
+		}
+	}
+
+	original, format, err := decodeImage(data, s.Limits.MaxPixels)
+	if errors.Is(err, errTooManyPixels) {
+		return nil, err
+	} else if err != nil {
+		return nil, nil
+	}
+	// JPEG and PNG were stored upright, but WebP still relies on its EXIF
+	// orientation, which the variants don't carry
+	if file.MimeType == "image/webp" && file.Metadata.Orientation > 1 {
+		original = orient(original, file.Metadata.Orientation)
+	}
+
+	extension := path.Ext(file.Key)
+	base := strings.TrimSuffix(file.Key, extension)
+
+	var variants []Variant
+	for _, spec := range specs {
+		resized := resize(original, spec.MaxEdge)
+		if resized == nil {
+			continue
+		}
+
+		// PNG keeps transparency; everything else becomes a JPEG.
+		var buf bytes.Buffer
+		variant := Variant{Name: spec.Name, Width: resized.Bounds().Dx(), Height: resized.Bounds().Dy()}
+		if format == "png" {
+			err = png.Encode(&buf, resized)
+			variant.MimeType = "image/png"
+			variant.Key = base + "_" + spec.Name + ".png"
+		} else {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+			variant.MimeType = "image/jpeg"
+			variant.Key = base + "_" + spec.Name + ".jpg"
+		}
+		if err != nil {
+			s.deleteVariants(variants)
+			return nil, fmt.Errorf("encode %s variant: %w", spec.Name, err)
+		}
+		variant.Size = int64(buf.Len())
+
+		if err := s.Store.Put(ctx, variant.Key, &buf, variant.Size, variant.MimeType); err != nil {
+			s.deleteVariants(variants)
+			return nil, fmt.Errorf("save %s variant: %w", spec.Name, err)
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+var errTooManyPixels = fmt.Errorf("%w: too many pixels", ErrInvalidImage)
+
+// decodeImage decodes data after checking from its header that it has at most
+// maxPixels pixels.
+func decodeImage(data []byte, maxPixels int64) (image.Image, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if maxPixels > 0 && int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, "", errTooManyPixels
+	}
+	return image.Decode(bytes.NewReader(data))
+}
+
+func (s *Service) deleteVariants(variants []Variant) {
+	for _, variant := range variants {
+		s.Delete(variant.Key)
+	}
+}
+
+// resize scales img down so its longest edge is maxEdge, or returns nil if it
+// is already that small.
+func resize(img image.Image, maxEdge int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxEdge && height <= maxEdge {
+		return nil
+	}
+
+	if width >= height {
+		height = height * maxEdge / width
+		width = maxEdge
+	} else {
+		width = width * maxEdge / height
+		height = maxEdge
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
@@ -0,0 +1,109 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mliem2k/ottb-go/storage"
+)
+
+// pngClaiming is a small PNG whose header declares width × height pixels.
+func pngClaiming(t *testing.T, width, height uint32) []byte {
+	data := testImage(t, func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) })
+	ihdr := data[len(pngSignature)+4 : len(pngSignature)+8+13]
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	binary.BigEndian.PutUint32(data[len(pngSignature)+8+13:], crc32.ChecksumIEEE(ihdr))
+	return data
+}
+
+func TestDecodeImageMaxPixels(t *testing.T) {
+	bomb := pngClaiming(t, 50000, 50000)
+	if _, _, err := decodeImage(bomb, 50_000_000); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("got %v, want ErrInvalidImage", err)
+	}
+
+	small := pngClaiming(t, 4, 2)
+	img, format, err := decodeImage(small, 8)
+	if err != nil || format != "png" || img.Bounds().Dx() != 4 {
+		t.Errorf("got %v, %q, %v; want the 4×2 image", img, format, err)
+	}
+	if _, _, err := decodeImage(small, 7); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("got %v for 8 pixels with a limit of 7, want ErrInvalidImage", err)
+	}
+}
+
+// fileHeader wraps data in a multipart form as a client would upload it.
+func fileHeader(t *testing.T, name string, data []byte) *multipart.FileHeader {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	return req.MultipartForm.File["image"][0]
+}
+
+func TestSaveVariantsWebPOrientation(t *testing.T) {
+	// A 75×100 lossless WebP, given an EXIF orientation of 6
+	webp, err := os.ReadFile("testdata/gopher.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := append([]byte("WEBP"), riffChunk("VP8X", []byte{0x08, 0, 0, 0, 74, 0, 0, 99, 0, 0})...)
+	body = append(body, webp[12:]...)
+	body = append(body, riffChunk("EXIF", orientationExif(6))...)
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	data = append(data, body...)
+
+	store, err := storage.NewLocalStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(store, Limits{MaxPixels: 50_000_000})
+
+	file, err := service.Save(context.Background(), "", fileHeader(t, "photo.webp", data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.MimeType != "image/webp" || file.Width != 100 || file.Height != 75 {
+		t.Errorf("got %s %d×%d, want image/webp 100×75", file.MimeType, file.Width, file.Height)
+	}
+
+	// Read the original back from the store rather than the request
+	file.data = nil
+	variants, err := service.SaveVariants(context.Background(), file, []VariantSpec{{Name: "small", MaxEdge: 50}})
+	if err != nil || len(variants) != 1 {
+		t.Fatalf("got %v, %v; want one variant", variants, err)
+	}
+	if variant := variants[0]; variant.Width != 50 || variant.Height != 37 {
+		t.Errorf("got variant %d×%d, want 50×37", variant.Width, variant.Height)
+	}
+
+	reader, err := store.Get(context.Background(), variants[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	config, _, err := image.DecodeConfig(reader)
+	if err != nil || config.Width != 50 || config.Height != 37 {
+		t.Errorf("got stored variant %d×%d, %v; want 50×37", config.Width, config.Height, err)
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EarthRadiusKm is the mean radius used for great-circle distances.
+const EarthRadiusKm = 6371.0
+
+var ErrInvalidCoordinates = errors.New("latitude must be between -90 and 90 and longitude between -180 and 180")
+
+// ValidateCoordinates checks that lat and lng are a point on Earth.
+func ValidateCoordinates(lat, lng float64) error {
+	if math.IsNaN(lat) || math.IsNaN(lng) || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return ErrInvalidCoordinates
+	}
+	return nil
+}
+
+// ParseLatLong reads a "lat,lng" pair such as "-6.2, 106.8". Whitespace and a
+// semicolon or space as separator are tolerated.
+func ParseLatLong(s string) (float64, float64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	})
+	if len(fields) != 2 {
+		return 0, 0, errors.New("expected a latitude and a longitude separated by a comma")
+	}
+
+	lat, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid latitude")
+	}
+	lng, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid longitude")
+	}
+	return lat, lng, ValidateCoordinates(lat, lng)
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	_ "golang.org/x/image/webp"
+)
+
+// ImageInfo describes the contents of an uploaded image file.
+type ImageInfo struct {
+	Size     int64
+	MimeType string
+	Width    int
+	Height   int
+	Checksum string
+}
+
+// InspectImage sniffs the content type, pixel dimensions and SHA-256 checksum
+// of data. Dimensions are left at zero for formats the standard library
+// cannot decode.
+func InspectImage(data []byte) ImageInfo {
+	sum := sha256.Sum256(data)
+	info := ImageInfo{
+		Size:     int64(len(data)),
+		MimeType: http.DetectContentType(data),
+		Checksum: hex.EncodeToString(sum[:]),
+	}
+
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		info.Width = config.Width
+		info.Height = config.Height
+	}
+
+	return info
+}
@@ -8,7 +8,22 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-func CreateToken(ttl time.Duration, payload interface{}, privateKey string) (string, error) {
+// TokenClaims are the claims of a token for payload that expires after ttl.
+// jti lets the caller tie the token to a server-side record.
+func TokenClaims(ttl time.Duration, payload interface{}, jti string) jwt.MapClaims {
+	now := time.Now().UTC()
+
+	claims := make(jwt.MapClaims)
+	claims["sub"] = payload
+	claims["jti"] = jti
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	return claims
+}
+
+// CreateTokenWithID signs TokenClaims with privateKey.
+func CreateTokenWithID(ttl time.Duration, payload interface{}, jti string, privateKey string) (string, error) {
 	decodedPrivateKey, err := base64.StdEncoding.DecodeString(privateKey)
 	if err != nil {
 		return "", fmt.Errorf("could not decode key: %w", err)
@@ -19,15 +34,7 @@ func CreateToken(ttl time.Duration, payload interface{}, privateKey string) (str
 		return "", fmt.Errorf("create: parse key: %w", err)
 	}
 
-	now := time.Now().UTC()
-
-	claims := make(jwt.MapClaims)
-	claims["sub"] = payload
-	claims["exp"] = now.Add(ttl).Unix()
-	claims["iat"] = now.Unix()
-	claims["nbf"] = now.Unix()
-
-	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, TokenClaims(ttl, payload, jti)).SignedString(key)
 
 	if err != nil {
 		return "", fmt.Errorf("create: sign token: %w", err)
@@ -36,7 +43,8 @@ func CreateToken(ttl time.Duration, payload interface{}, privateKey string) (str
 	return token, nil
 }
 
-func ValidateToken(token string, publicKey string) (interface{}, error) {
+// ValidateTokenClaims verifies the token and returns all of its claims.
+func ValidateTokenClaims(token string, publicKey string) (jwt.MapClaims, error) {
 	decodedPublicKey, err := base64.StdEncoding.DecodeString(publicKey)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode: %w", err)
@@ -45,7 +53,7 @@ func ValidateToken(token string, publicKey string) (interface{}, error) {
 	key, err := jwt.ParseRSAPublicKeyFromPEM(decodedPublicKey)
 
 	if err != nil {
-		return "", fmt.Errorf("validate: parse key: %w", err)
+		return nil, fmt.Errorf("validate: parse key: %w", err)
 	}
 
 	parsedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
@@ -64,5 +72,5 @@ func ValidateToken(token string, publicKey string) (interface{}, error) {
 		return nil, fmt.Errorf("validate: invalid token")
 	}
 
-	return claims["sub"], nil
+	return claims, nil
 }
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key of RFC 6238, "12345678901234567890",
+// in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC's SHA-1 vectors, cut to the last six of their eight digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		code, err := TOTPCode(rfc6238Secret, TOTPCounter(time.Unix(vector.unix, 0)))
+		if err != nil || code != vector.code {
+			t.Errorf("at %d: got %q, %v; want %q", vector.unix, code, err, vector.code)
+		}
+	}
+
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("accepted an invalid secret")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		at := time.Unix(vector.unix, 0)
+		step := TOTPCounter(at)
+		for _, test := range []struct {
+			name   string
+			offset time.Duration
+			ok     bool
+		}{
+			{"on time", 0, true},
+			{"one step late", totpPeriod * time.Second, true},
+			{"one step early", -totpPeriod * time.Second, true},
+			{"two steps late", 2 * totpPeriod * time.Second, false},
+			{"two steps early", -2 * totpPeriod * time.Second, false},
+		} {
+			if at.Add(test.offset).Unix() < 0 {
+				continue // time steps start at the epoch
+			}
+			counter, ok := ValidateTOTP(rfc6238Secret, vector.code, at.Add(test.offset))
+			if ok != test.ok || (ok && counter != step) {
+				t.Errorf("at %d, %s: got %d, %v; want %d, %v", vector.unix, test.name, counter, ok, step, test.ok)
+			}
+		}
+	}
+
+	at := time.Unix(1111111111, 0)
+	for _, code := range []string{"", "05047", "0504710", "123456"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, at); ok {
+			t.Errorf("accepted %q", code)
+		}
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, "050 471", at); !ok {
+		t.Error("refused a code with a space in it")
+	}
+}
+
+// TestTOTPReplay checks that the counters ValidateTOTP returns are what it
+// takes to refuse a code twice, the way the last accepted counter is kept
+// per user: a code is only good for a step after the last one used.
+func TestTOTPReplay(t *testing.T) {
+	var last int64 = -1
+	accept := func(code string, at time.Time) bool {
+		counter, ok := ValidateTOTP(rfc6238Secret, code, at)
+		if !ok || counter <= last {
+			return false
+		}
+		last = counter
+		return true
+	}
+
+	at := time.Unix(1111111111, 0)
+	current, _ := TOTPCode(rfc6238Secret, TOTPCounter(at))
+	previous, _ := TOTPCode(rfc6238Secret, TOTPCounter(at)-1)
+	next, _ := TOTPCode(rfc6238Secret, TOTPCounter(at)+1)
+
+	if !accept(current, at) {
+		t.Fatal("refused the current code")
+	}
+	if accept(current, at.Add(10*time.Second)) {
+		t.Error("accepted the same code twice")
+	}
+	if accept(previous, at) {
+		t.Error("accepted the previous step's code after the current one")
+	}
+	if !accept(next, at.Add(totpPeriod*time.Second)) {
+		t.Error("refused the next step's code")
+	}
+}